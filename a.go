@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 🔐 ACCESS & API AUTH
+// ---------------------------------------------------------
+
+func isAdmin(user string) bool {
+	for _, a := range AdminNumbers {
+		if getCleanID(normalizeNumber(a)) == user {
+			return true
+		}
+	}
+	return false
+}
+
+// commandRole اس سیشن پر بھیجنے والے کا درجہ
+// owner: سیشن کا اپنا نمبر (یا Note to self) اور ADMIN_NUMBERS
+// admin/viewer: session_members میں؛ باقی سب public
+func commandRole(session, user string, fromMe bool) Role {
+	if fromMe || user == session || isAdmin(user) {
+		return RoleOwner
+	}
+	for _, m := range GetMembers(session) {
+		if m.Member != user {
+			continue
+		}
+		switch m.Role {
+		case "admin":
+			return RoleAdmin
+		case "viewer":
+			return RoleViewer
+		}
+	}
+	return RolePublic
+}
+
+// isSessionMember سیشن کا اپنا نمبر یا session_members میں کوئی بھی role
+func isSessionMember(session, user string) bool {
+	if user == session {
+		return true
+	}
+	for _, m := range GetMembers(session) {
+		if m.Member == user {
+			return true
+		}
+	}
+	return false
+}
+
+// canViewNumber صرف admin یا وہ جو اس نمبر کو watch کر رہا ہو
+func canViewNumber(user, phone string) bool {
+	if isAdmin(user) {
+		return true
+	}
+	for _, w := range GetWatches(user) {
+		if w.Number == normalizeNumber(phone) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupOTPs نمبر یا suffix کے ریکارڈز، صرف وہ جو user دیکھ سکتا ہے
+// (watched نمبرز کا فلٹر SQL میں، تاکہ LIMIT صرف اجازت والی rows پر لگے)
+func lookupOTPs(user, number string, limit int) []OTPRecord {
+	if isAdmin(user) {
+		return FindOTPRecords(number, limit)
+	}
+	return FindWatchedOTPRecords(user, number, limit)
+}
+
+func newAPIToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// authenticateRequest صرف "Authorization: Bearer <token>" سے user نکالتا ہے
+// (query string والا token proxy/access logs میں چلا جاتا ہے)
+// ADMIN_TOKEN والی request کو admin مانا جاتا ہے
+func authenticateRequest(r *http.Request) (user string, admin bool, ok bool) {
+	return authenticateToken(strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")))
+}
+
+// authenticateStream SSE/WebSocket handshake: براؤزر کا EventSource/WebSocket ہیڈر نہیں بھیج سکتا،
+// اس لیے صرف یہاں "?token=" بھی
+func authenticateStream(r *http.Request) (user string, admin bool, ok bool) {
+	if user, admin, ok = authenticateRequest(r); ok {
+		return
+	}
+	return authenticateToken(r.URL.Query().Get("token"))
+}
+
+func authenticateToken(token string) (user string, admin bool, ok bool) {
+	if token == "" {
+		return "", false, false
+	}
+	if AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(AdminToken)) == 1 {
+		return "admin", true, true
+	}
+	owner, found := GetTokenOwner(token)
+	if !found {
+		return "", false, false
+	}
+	return owner, isAdmin(owner), true
+}
+
+// GET /api/otp?number=923001234567&limit=5
+func handleOTPLookupAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	user, admin, ok := authenticateRequest(r)
+	if !ok {
+		http.Error(w, `{"error":"Unauthorized"}`, 401)
+		return
+	}
+
+	number := normalizeNumber(r.URL.Query().Get("number"))
+	if !isDigits(number) || len(number) < 4 {
+		http.Error(w, `{"error":"Invalid number"}`, 400)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 50 {
+		limit = 5
+	}
+
+	var records []OTPRecord
+	if admin {
+		records = FindOTPRecords(number, limit)
+	} else {
+		records = lookupOTPs(user, number, limit)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"number":  number,
+		"records": records,
+	})
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// ---------------------------------------------------------
+// 💾 ENCRYPTED BACKUP / RESTORE
+// ---------------------------------------------------------
+
+// Archive format: "KAMIBAK1" | salt(16) | nonce(12) | AES-256-GCM(tar.gz)
+// tar.gz میں manifest.json اور دونوں ڈیٹابیس فائلیں
+const (
+	backupMagic      = "KAMIBAK1"
+	backupIterations = 600000
+	backupMaxSize    = 512 << 20
+)
+
+type backupManifest struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Files     map[string]string `json:"files"` // name -> sha256
+}
+
+// backupFiles archive میں نام → ڈسک پر path
+func backupFiles() map[string]string {
+	return map[string]string{
+		"kami_sessions.db": sessionsDBPath,
+		"kami_bot.db":      dbPath,
+	}
+}
+
+// restoreGate پس منظر jobs (OTP poller، digest، prune) اور کمانڈز ہر چکر میں RLock لیتے ہیں؛
+// restore پورا Lock لیتا ہے تاکہ کوئی بند یا آدھا بدلا DB نہ دیکھے (ورنہ IsOTPSent پوری فیڈ دوبارہ بھیج دے)
+var restoreGate sync.RWMutex
+
+// CreateBackup دونوں SQLite فائلوں کا consistent snapshot (VACUUM INTO) لے کر encrypt کرتا ہے
+func CreateBackup(passphrase string) ([]byte, error) {
+	if DatabaseURL != "" {
+		return nil, fmt.Errorf("Backups cover SQLite files only; use your PostgreSQL provider's backups")
+	}
+	if len(passphrase) < 8 {
+		return nil, fmt.Errorf("Passphrase must be at least 8 characters")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "kami-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest := backupManifest{Version: 1, CreatedAt: time.Now().UTC(), Files: map[string]string{}}
+	snapshots := map[string][]byte{}
+	for name, path := range backupFiles() {
+		snap := filepath.Join(tmpDir, name)
+		if err := snapshotSQLite(path, snap); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		data, err := os.ReadFile(snap)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		manifest.Files[name] = hex.EncodeToString(sum[:])
+		snapshots[name] = data
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	writeEntry := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: manifest.CreatedAt}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	mdata, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := writeEntry("manifest.json", mdata); err != nil {
+		return nil, err
+	}
+	for name, data := range snapshots {
+		if err := writeEntry(name, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return encryptBackup(buf.Bytes(), passphrase)
+}
+
+// snapshotSQLite چلتے ڈیٹابیس کی ٹرانزیکشنل کاپی
+func snapshotSQLite(src, dst string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	conn, err := sql.Open("sqlite3", "file:"+src+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Exec("VACUUM INTO '" + strings.ReplaceAll(dst, "'", "''") + "'")
+	return err
+}
+
+func backupKey(passphrase string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, backupIterations, 32)
+}
+
+func encryptBackup(plain []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	key, err := backupKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+
+	out := append([]byte(backupMagic), salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plain, []byte(backupMagic)), nil
+}
+
+func decryptBackup(archive []byte, passphrase string) ([]byte, error) {
+	if len(archive) < len(backupMagic)+16+12 || string(archive[:len(backupMagic)]) != backupMagic {
+		return nil, fmt.Errorf("Not a Kami backup archive")
+	}
+	rest := archive[len(backupMagic):]
+	salt, rest := rest[:16], rest[16:]
+	key, err := backupKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce, sealed := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, []byte(backupMagic))
+	if err != nil {
+		return nil, fmt.Errorf("Wrong passphrase or corrupted archive")
+	}
+	return plain, nil
+}
+
+// extractBackup archive کھول کر ہر فائل کو manifest اور SQLite integrity سے جانچتا ہے
+// کامیابی پر فائلیں dir میں لکھی جاتی ہیں
+func extractBackup(archive []byte, passphrase, dir string) error {
+	plain, err := decryptBackup(archive, passphrase)
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest *backupManifest
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, backupMaxSize))
+		if err != nil {
+			return err
+		}
+		if hdr.Name == "manifest.json" {
+			manifest = &backupManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return fmt.Errorf("Invalid manifest: %v", err)
+			}
+			continue
+		}
+		if _, known := backupFiles()[hdr.Name]; known {
+			files[hdr.Name] = data
+		}
+	}
+	if manifest == nil {
+		return fmt.Errorf("Archive has no manifest")
+	}
+
+	for name := range backupFiles() {
+		data, ok := files[name]
+		if !ok {
+			return fmt.Errorf("Archive is missing %s", name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != manifest.Files[name] {
+			return fmt.Errorf("Checksum mismatch for %s", name)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+		if err := checkSQLiteFile(path); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func checkSQLiteFile(path string) error {
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var result string
+	if err := conn.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
+
+// swapDataFiles موجودہ فائلیں (WAL سمیت) .bak-<time> بنا کر restored فائلیں ان کی جگہ رکھتا ہے
+// پہلے سب فائلیں ہدف والی ڈائریکٹری میں stage ہوتی ہیں، پھر صرف renames (ہر ایک atomic)؛
+// بیچ میں کوئی قدم ناکام ہو تو سب کچھ واپس۔ واپسی والا undo بعد کی ناکامی (مثلاً DB نہ کھلے) کے لیے
+func swapDataFiles(dir string) (undo func() error, err error) {
+	stamp := time.Now().Format("20060102-150405")
+
+	// 1. Stage: ہر فائل اپنے ہدف کے ساتھ (same volume، تاکہ rename atomic ہو)
+	staged := map[string]string{} // path -> staged path
+	cleanup := func() {
+		for _, s := range staged {
+			os.Remove(s)
+		}
+	}
+	for name, path := range backupFiles() {
+		s := path + ".restore-" + stamp
+		if err := moveFile(filepath.Join(dir, name), s); err != nil {
+			cleanup()
+			return nil, err
+		}
+		staged[path] = s
+	}
+
+	// 2. Swap: ہر rename ریکارڈ، تاکہ الٹی ترتیب میں واپس ہو سکے
+	type rename struct{ from, to string }
+	done := []rename{}
+	rollback := func() error {
+		var firstErr error
+		for i := len(done) - 1; i >= 0; i-- {
+			if err := os.Rename(done[i].to, done[i].from); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		done = nil
+		cleanup()
+		return firstErr
+	}
+	move := func(from, to string) error {
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		done = append(done, rename{from, to})
+		return nil
+	}
+
+	for path, s := range staged {
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			if _, err := os.Stat(path + suffix); err != nil {
+				continue
+			}
+			if err := move(path+suffix, path+suffix+".bak-"+stamp); err != nil {
+				rollback()
+				return nil, err
+			}
+		}
+		if err := move(s, path); err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+	return rollback, nil
+}
+
+// moveFile temp dir دوسرے volume پر ہو سکتا ہے، اس لیے rename ناکام ہو تو copy
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0600)
+}
+
+// RestoreBackup چلتے بوٹ میں: validate → سیشنز بند → فائلیں swap → سب دوبارہ connect
+func RestoreBackup(archive []byte, passphrase string) error {
+	if DatabaseURL != "" {
+		return fmt.Errorf("Restore covers SQLite files only; use your PostgreSQL provider's restore")
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dbPath), "restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := extractBackup(archive, passphrase, tmpDir); err != nil {
+		return err
+	}
+	fmt.Println("💾 [RESTORE] Archive validated, pausing jobs and stopping sessions...")
+	restoreGate.Lock()
+	defer restoreGate.Unlock()
+
+	ClientMutex.Lock()
+	for _, cli := range ActiveClients {
+		cli.Disconnect()
+	}
+	ActiveClients = make(map[string]*whatsmeow.Client)
+	ClientMutex.Unlock()
+
+	closeStores()
+	undo, err := swapDataFiles(tmpDir)
+	if err == nil {
+		if err = openStores(); err != nil {
+			// نئی فائلیں نہیں کھلیں: پرانی واپس رکھ کر انہی کو دوبارہ کھولیں
+			closeStores()
+			if uerr := undo(); uerr != nil {
+				return fmt.Errorf("%v (rollback failed: %v)", err, uerr)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Println("❌ [RESTORE] Failed, keeping previous data:", err)
+		if oerr := openStores(); oerr != nil {
+			return fmt.Errorf("%v (reopen failed: %v)", err, oerr)
+		}
+		go StartAllBots()
+		return err
+	}
+
+	// backup کی sent_history پرانی ہے: جو OTPs ابھی فیڈ میں ہیں وہ بھیجے جا چکے، دوبارہ نہ جائیں
+	for id := range allLiveFeedIDs() {
+		MarkOTPSent(id)
+	}
+
+	fmt.Println("💾 [RESTORE] Data swapped, reconnecting sessions...")
+	go func() {
+		StartAllBots()
+		InitLIDSystem()
+	}()
+	return nil
+}
+
+func closeStores() {
+	if sc := sessionStore(); sc != nil {
+		sc.Close()
+	}
+	db.Close()
+}
+
+// openStores دونوں ڈیٹابیس دوبارہ کھول کر کیشز صاف کرتا ہے
+func openStores() error {
+	if err := openBotDB(); err != nil {
+		return err
+	}
+	resetCaches()
+	return openSessionStore()
+}
+
+// requestPassphrase "X-Backup-Passphrase" ہیڈر، ورنہ BACKUP_PASSPHRASE
+func requestPassphrase(r *http.Request) string {
+	if p := r.Header.Get("X-Backup-Passphrase"); p != "" {
+		return p
+	}
+	return BackupPassphrase
+}
+
+// GET /admin/backup (Authorization: Bearer ADMIN_TOKEN)
+func handleBackupAPI(w http.ResponseWriter, r *http.Request) {
+	if _, admin, ok := authenticateRequest(r); !ok || !admin {
+		http.Error(w, `{"error":"Unauthorized"}`, 401)
+		return
+	}
+	archive, err := CreateBackup(requestPassphrase(r))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 500)
+		return
+	}
+	name := "kami_backup_" + time.Now().UTC().Format("20060102-150405") + ".kbak"
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Write(archive)
+}
+
+// POST /admin/restore (body = archive, Authorization: Bearer ADMIN_TOKEN)
+func handleRestoreAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	if _, admin, ok := authenticateRequest(r); !ok || !admin {
+		http.Error(w, `{"error":"Unauthorized"}`, 401)
+		return
+	}
+	archive, err := io.ReadAll(io.LimitReader(r.Body, backupMaxSize))
+	if err != nil {
+		http.Error(w, `{"error":"Could not read body"}`, 400)
+		return
+	}
+	if err := RestoreBackup(archive, requestPassphrase(r)); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 400)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "Restored, sessions reconnecting"})
+}
+
+// ---------------------------------------------------------
+// 🖥️ CLI MODE
+// ---------------------------------------------------------
+
+// runCLI بوٹ بند ہونے کی حالت میں (BACKUP_PASSPHRASE ضروری)
+func runCLI(args []string) {
+	usage := "Usage: bot backup <out.kbak> | bot restore <in.kbak>   (env BACKUP_PASSPHRASE)"
+	if len(args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "backup":
+		archive, err := CreateBackup(BackupPassphrase)
+		if err == nil {
+			err = os.WriteFile(args[1], archive, 0600)
+		}
+		if err != nil {
+			fmt.Println("❌ Backup failed:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Backup written to %s (%d bytes)\n", args[1], len(archive))
+
+	case "restore":
+		archive, err := os.ReadFile(args[1])
+		if err != nil {
+			fmt.Println("❌ Restore failed:", err)
+			os.Exit(1)
+		}
+		os.MkdirAll(filepath.Dir(dbPath), 0755)
+		tmpDir, err := os.MkdirTemp(filepath.Dir(dbPath), "restore-")
+		if err == nil {
+			defer os.RemoveAll(tmpDir)
+			err = extractBackup(archive, BackupPassphrase, tmpDir)
+		}
+		if err == nil {
+			_, err = swapDataFiles(tmpDir)
+		}
+		if err != nil {
+			fmt.Println("❌ Restore failed:", err)
+			os.RemoveAll(tmpDir)
+			os.Exit(1)
+		}
+		fmt.Println("✅ Restore complete. Start the bot normally to reconnect sessions.")
+
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
@@ -2,22 +2,78 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"os"
-//	"path/filepath"
-	"sync"
+	"path/filepath"
+	"sync/atomic"
 	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var (
-	db       *sql.DB
-	dbMutex  sync.Mutex
-	dbPath   = "./data/kami_bot.db"
+	db        = &sharedDB{}
+	dbPath    = "./data/kami_bot.db"
+	dbDialect = botDialect() // DATABASE_URL ہو تو "postgres"
 )
 
+func botDialect() string {
+	if DatabaseURL != "" {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// sharedDB connection pool کے گرد پتلا wrapper: کوئی global lock نہیں،
+// restore پر پورا pool atomically بدل جاتا ہے
+type sharedDB struct{ pool atomic.Pointer[sql.DB] }
+
+func (s *sharedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.pool.Load().Exec(query, args...)
+}
+
+func (s *sharedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.pool.Load().Query(query, args...)
+}
+
+func (s *sharedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return s.pool.Load().QueryRow(query, args...)
+}
+
+func (s *sharedDB) Begin() (*sql.Tx, error) {
+	return s.pool.Load().Begin()
+}
+
+func (s *sharedDB) Close() error {
+	return s.pool.Load().Close()
+}
+
+// withTx fn کو ایک ٹرانزیکشن میں چلاتا ہے (read-modify-write کے لیے)۔
+// SQLite پر BEGIN IMMEDIATE (DSN میں _txlock)، PostgreSQL پر lockKey کا advisory lock
+func withTx(lockKey string, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if dbDialect == "postgres" && lockKey != "" {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", lockKey); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// queryer *sql.DB، sharedDB اور *sql.Tx تینوں پر چلنے والی reads کے لیے
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
 type UserSettings struct {
 	JID        string
 	Channels   []string
@@ -25,123 +81,915 @@ type UserSettings struct {
 }
 
 func InitDB() {
-	// Ensure data directory exists
-	if _, err := os.Stat("./data"); os.IsNotExist(err) {
-		os.Mkdir("./data", 0755)
+	if err := openBotDB(); err != nil {
+		panic("❌ " + err.Error())
 	}
+}
 
+// openBotDB restore میں بھی: غلطی پر panic نہیں، error واپس
+func openBotDB() error {
+	// Ensure data directory exists
+	os.MkdirAll(filepath.Dir(dbPath), 0755)
+
+	var conn *sql.DB
 	var err error
-	db, err = sql.Open("sqlite3", dbPath)
-	if err != nil {
-		panic("❌ Could not connect to SQLite: " + err.Error())
+	if dbDialect == "postgres" {
+		conn, err = sql.Open("postgres", DatabaseURL)
+		if err == nil {
+			err = conn.Ping()
+		}
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return fmt.Errorf("Could not connect to PostgreSQL: %v", err)
+		}
+	} else {
+		// WAL: reads writes کو block نہیں کرتے؛ busy_timeout: writers باری کا انتظار کریں
+		conn, err = sql.Open("sqlite3", "file:"+dbPath+"?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_txlock=immediate")
+		if err == nil {
+			err = conn.Ping()
+		}
+		if err != nil {
+			if conn != nil {
+				conn.Close()
+			}
+			return fmt.Errorf("Could not connect to SQLite: %v", err)
+		}
 	}
+	conn.SetMaxOpenConns(DBMaxConns)
+	conn.SetMaxIdleConns(DBMaxConns)
+	conn.SetConnMaxIdleTime(5 * time.Minute)
+	db.pool.Store(conn)
 
-	// Table for User Settings (Channels & Links)
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS user_settings (
-		jid TEXT PRIMARY KEY,
-		channels TEXT,
-		custom_link TEXT
-	)`)
-	if err != nil {
-		panic(err)
+	if err := runMigrations(); err != nil {
+		return err
 	}
 
-	// Table for Sent OTP History (Global Deduplication)
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS sent_history (
-		msg_id TEXT PRIMARY KEY,
-		created_at DATETIME
-	)`)
-	if err != nil {
-		panic(err)
+	if dbDialect == "postgres" {
+		fmt.Println("✅ PostgreSQL Database Initialized (DATABASE_URL)")
+	} else {
+		fmt.Println("✅ SQLite Database Initialized at", dbPath)
 	}
-
-	fmt.Println("✅ SQLite Database Initialized at", dbPath)
+	return nil
 }
 
 // --- User Settings Functions ---
 
 func GetUserSettings(jid string) UserSettings {
-	dbMutex.Lock()
-	defer dbMutex.Unlock()
+	return userSettingsCache.get(jid, func() (UserSettings, error) { return loadUserSettings(jid) })
+}
 
-	var channelsJSON, link string
-	row := db.QueryRow("SELECT channels, custom_link FROM user_settings WHERE jid = ?", jid)
-	err := row.Scan(&channelsJSON, &link)
+func loadUserSettings(jid string) (UserSettings, error) {
+	settings := UserSettings{JID: jid, CustomLink: DefaultLink, Channels: []string{}}
 
-	settings := UserSettings{JID: jid, CustomLink: DefaultLink}
-	
-	if err == nil {
-		json.Unmarshal([]byte(channelsJSON), &settings.Channels)
-		if link != "" {
-			settings.CustomLink = link
+	var link sql.NullString
+	err := db.QueryRow("SELECT custom_link FROM user_settings WHERE jid = $1", jid).Scan(&link)
+	if err == nil && link.String != "" {
+		settings.CustomLink = link.String
+	} else if err != nil && err != sql.ErrNoRows {
+		return settings, err
+	}
+
+	rows, err := db.Query("SELECT jid FROM channels WHERE owner = $1 ORDER BY created_at, jid", jid)
+	if err != nil {
+		return settings, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ch string
+		if rows.Scan(&ch) == nil {
+			settings.Channels = append(settings.Channels, ch)
 		}
-	} else {
-		// Return empty settings if not found
-		settings.Channels = []string{}
 	}
-	return settings
+	return settings, rows.Err()
 }
 
 func AddChannel(jid, channelID string) error {
-	settings := GetUserSettings(jid)
-	// Check if already exists
-	for _, ch := range settings.Channels {
-		if ch == channelID {
-			return fmt.Errorf("Channel already added")
-		}
+	res, err := db.Exec("INSERT INTO channels (owner, jid, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", jid, channelID, time.Now())
+	if err != nil {
+		return err
+	}
+	userSettingsCache.invalidate(jid)
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("Channel already added")
 	}
-	settings.Channels = append(settings.Channels, channelID)
-	return saveSettings(settings)
+	return nil
 }
 
+// RemoveChannel چینل کے ساتھ اس کا شیڈول بھی جاتا ہے، اس لیے held OTPs بھی
 func RemoveChannel(jid, channelID string) error {
-	settings := GetUserSettings(jid)
-	newChannels := []string{}
-	found := false
-	for _, ch := range settings.Channels {
-		if ch == channelID {
-			found = true
-			continue
+	err := withTx("schedule:"+jid, func(tx *sql.Tx) error {
+		res, err := tx.Exec("DELETE FROM channels WHERE owner = $1 AND jid = $2", jid, channelID)
+		if err != nil {
+			return err
 		}
-		newChannels = append(newChannels, ch)
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("Channel not found")
+		}
+		_, err = deleteHeldOTPs(tx, jid, channelID)
+		return err
+	})
+	userSettingsCache.invalidate(jid)
+	scheduleCache.invalidate(jid + "|" + channelID)
+	return err
+}
+
+// GetChannelOwners وہ تمام users جنہوں نے یہ JID ایکٹو کیا ہے
+func GetChannelOwners(channelID string) []string {
+	owners := []string{}
+	rows, err := db.Query("SELECT owner FROM channels WHERE jid = $1 ORDER BY owner", channelID)
+	if err != nil {
+		return owners
 	}
-	if !found {
-		return fmt.Errorf("Channel not found")
+	defer rows.Close()
+	for rows.Next() {
+		var o string
+		if rows.Scan(&o) == nil {
+			owners = append(owners, o)
+		}
 	}
-	settings.Channels = newChannels
-	return saveSettings(settings)
+	return owners
 }
 
 func SetCustomLink(jid, link string) error {
-	settings := GetUserSettings(jid)
-	settings.CustomLink = link
-	return saveSettings(settings)
-}
-
-func saveSettings(s UserSettings) error {
-	dbMutex.Lock()
-	defer dbMutex.Unlock()
-	
-	data, _ := json.Marshal(s.Channels)
-	_, err := db.Exec(`INSERT INTO user_settings (jid, channels, custom_link) VALUES (?, ?, ?) 
-		ON CONFLICT(jid) DO UPDATE SET channels = ?, custom_link = ?`,
-		s.JID, string(data), s.CustomLink, string(data), s.CustomLink)
+	_, err := db.Exec(`INSERT INTO user_settings (jid, custom_link) VALUES ($1, $2)
+		ON CONFLICT(jid) DO UPDATE SET custom_link = excluded.custom_link`, jid, link)
+	userSettingsCache.invalidate(jid)
 	return err
 }
 
 
+// IsOTPSent DB کی عارضی غلطی پر بھی true: OTP اس چکر میں رک جاتا ہے اور اگلے poll میں
+// دوبارہ دیکھا جاتا ہے، ورنہ پوری فیڈ دوبارہ بھیج دی جاتی
 func IsOTPSent(id string) bool {
-	dbMutex.Lock()
-	defer dbMutex.Unlock()
+	if sentFingerprints.has(id) {
+		return true
+	}
 	var exists int
-	err := db.QueryRow("SELECT 1 FROM sent_history WHERE msg_id = ?", id).Scan(&exists)
+	err := db.QueryRow("SELECT 1 FROM sent_history WHERE msg_id = $1", id).Scan(&exists)
+	if err == nil {
+		sentFingerprints.add(id)
+	} else if err != sql.ErrNoRows {
+		fmt.Printf("⚠️ [DEDUP] %s: %v\n", id, err)
+		return true
+	}
 	return err == nil
 }
 
 func MarkOTPSent(id string) {
-	dbMutex.Lock()
-	defer dbMutex.Unlock()
-	db.Exec("INSERT INTO sent_history (msg_id, created_at) VALUES (?, ?)", id, time.Now())
-	
-	// Optional: Cleanup old history every now and then (not implemented here for brevity)
+	if _, err := db.Exec("INSERT INTO sent_history (msg_id, created_at) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, time.Now()); err == nil {
+		sentFingerprints.add(id)
+	}
+}
+
+// PruneSentHistory cutoff سے پرانی rows ہٹاتا ہے، سوائے ان کے جو ابھی بھی فیڈ میں ہیں
+// (ورنہ وہی OTP اگلے poll میں دوبارہ "نیا" سمجھا جائے گا)
+func PruneSentHistory(cutoff time.Time, keep map[string]bool) (int, error) {
+	rows, err := db.Query("SELECT msg_id FROM sent_history WHERE created_at < $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	stale := []string{}
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil && !keep[id] {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range stale {
+		if _, err := tx.Exec("DELETE FROM sent_history WHERE msg_id = $1", id); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return len(stale), tx.Commit()
+}
+
+// TableSizes health کے لیے ہر ٹیبل کی row count
+func TableSizes() map[string]int {
+	sizes := map[string]int{}
+	query := "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name"
+	if dbDialect == "postgres" {
+		// سیشنز بھی اسی ڈیٹابیس میں ہیں، whatsmeow کے ٹیبلز الگ رکھیں
+		query = `SELECT table_name FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' AND table_name NOT LIKE 'whatsmeow_%' ORDER BY table_name`
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return sizes
+	}
+	names := []string{}
+	for rows.Next() {
+		var n string
+		if rows.Scan(&n) == nil {
+			names = append(names, n)
+		}
+	}
+	rows.Close()
+	for _, n := range names {
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM " + n).Scan(&count)
+		sizes[n] = count
+	}
+	return sizes
+}
+
+// --- Failover Sessions ---
+
+func AddBackup(owner, backup string) error {
+	if owner == backup {
+		return fmt.Errorf("Session cannot be its own backup")
+	}
+	res, err := db.Exec("INSERT INTO session_backups (owner, backup, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", owner, backup, time.Now())
+	if err != nil {
+		return err
+	}
+	backupsCache.invalidate(owner)
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("Backup already added")
+	}
+	return nil
+}
+
+// AcceptBackup backup سیشن کا مالک owner کی درخواست قبول کرتا ہے
+func AcceptBackup(owner, backup string) error {
+	res, err := db.Exec("UPDATE session_backups SET accepted_at = $1 WHERE owner = $2 AND backup = $3 AND accepted_at IS NULL", time.Now(), owner, backup)
+	if err != nil {
+		return err
+	}
+	backupsCache.invalidate(owner)
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("No pending backup request from %s", owner)
+	}
+	return nil
+}
+
+func RemoveBackup(owner, backup string) error {
+	res, err := db.Exec("DELETE FROM session_backups WHERE owner = $1 AND backup = $2", owner, backup)
+	if err != nil {
+		return err
+	}
+	backupsCache.invalidate(owner)
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("Backup not found")
+	}
+	return nil
+}
+
+// GetBackups صرف قبول شدہ، اسی ترتیب میں جس میں شامل کیے گئے (پہلا = پہلی ترجیح)
+func GetBackups(owner string) []string {
+	return backupsCache.get(owner, func() ([]string, error) { return loadBackups(owner) })
+}
+
+func loadBackups(owner string) ([]string, error) {
+	backups := []string{}
+	rows, err := db.Query("SELECT backup FROM session_backups WHERE owner = $1 AND accepted_at IS NOT NULL ORDER BY created_at, backup", owner)
+	if err != nil {
+		return backups, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b string
+		if rows.Scan(&b) == nil {
+			backups = append(backups, b)
+		}
+	}
+	return backups, rows.Err()
+}
+
+type BackupLink struct {
+	Owner    string
+	Backup   string
+	Accepted bool
+}
+
+// GetBackupLinks owner کی تمام backups (زیر التوا بھی)
+func GetBackupLinks(owner string) []BackupLink {
+	return queryBackupLinks("SELECT owner, backup, accepted_at IS NOT NULL FROM session_backups WHERE owner = $1 ORDER BY created_at, backup", owner)
+}
+
+// GetBackupRequests وہ سیشنز جنہوں نے backup کو اپنا backup بنانا چاہا
+func GetBackupRequests(backup string) []BackupLink {
+	return queryBackupLinks("SELECT owner, backup, accepted_at IS NOT NULL FROM session_backups WHERE backup = $1 ORDER BY created_at, owner", backup)
+}
+
+func queryBackupLinks(query, arg string) []BackupLink {
+	links := []BackupLink{}
+	rows, err := db.Query(query, arg)
+	if err != nil {
+		return links
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l BackupLink
+		if rows.Scan(&l.Owner, &l.Backup, &l.Accepted) == nil {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// --- Delivery Audit Log ---
+
+func LogDelivery(msgID, target, owner, session string, sendErr error) {
+	status, errText := "sent", ""
+	if sendErr != nil {
+		status, errText = "failed", sendErr.Error()
+	}
+	db.Exec(`INSERT INTO delivery_log (msg_id, target, owner, session, kind, status, error, created_at) VALUES ($1, $2, $3, $4, 'whatsapp', $5, $6, $7)`,
+		msgID, target, owner, session, status, errText, time.Now())
+}
+
+// LogSinkDelivery Telegram/Discord؛ کوئی سیشن نہیں، اس لیے .status کے اعداد میں شامل نہیں
+func LogSinkDelivery(msgID, target, owner, kind string, sendErr error) {
+	status, errText := "sent", ""
+	if sendErr != nil {
+		status, errText = "failed", sendErr.Error()
+	}
+	db.Exec(`INSERT INTO delivery_log (msg_id, target, owner, session, kind, status, error, created_at) VALUES ($1, $2, $3, '', $4, $5, $6, $7)`,
+		msgID, target, owner, kind, status, errText, time.Now())
+}
+
+// LastDelivery مالک کی آخری کامیاب WhatsApp ڈیلیوری
+func LastDelivery(owner string) (DeliveryRecord, bool) {
+	d := DeliveryRecord{Owner: owner, Status: "sent"}
+	err := db.QueryRow(`SELECT d.msg_id, d.target, d.session, d.created_at, COALESCE(h.phone, ''), COALESCE(h.service, ''), COALESCE(h.source, 0)
+		FROM delivery_log d LEFT JOIN otp_history h ON h.msg_id = d.msg_id
+		WHERE d.owner = $1 AND d.kind = 'whatsapp' AND d.status = 'sent' ORDER BY d.created_at DESC LIMIT 1`, owner).
+		Scan(&d.MsgID, &d.Target, &d.Session, &d.CreatedAt, &d.Phone, &d.Service, &d.Source)
+	return d, err == nil
+}
+
+// CountDeliveries صرف WhatsApp ڈیلیوریز (سیشن کی صحت)، sinks نہیں
+func CountDeliveries(owner, status string, since time.Time) int {
+	n := 0
+	db.QueryRow("SELECT COUNT(*) FROM delivery_log WHERE owner = $1 AND kind = 'whatsapp' AND status = $2 AND created_at >= $3", owner, status, since).Scan(&n)
+	return n
+}
+
+// --- Number Watches ---
+
+type Watch struct {
+	Watcher string
+	Number  string
+	DMJID   string
+	Session string
+}
+
+func AddWatch(w Watch) error {
+	res, err := db.Exec("INSERT INTO watches (watcher, number, dm_jid, session, created_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING",
+		w.Watcher, w.Number, w.DMJID, w.Session, time.Now())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("Number already watched")
+	}
+	return nil
+}
+
+// RemoveWatch number خالی ہو تو تمام watches ختم
+func RemoveWatch(watcher, number string) (int64, error) {
+	var res sql.Result
+	var err error
+	if number == "" {
+		res, err = db.Exec("DELETE FROM watches WHERE watcher = $1", watcher)
+	} else {
+		res, err = db.Exec("DELETE FROM watches WHERE watcher = $1 AND number = $2", watcher, number)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return 0, fmt.Errorf("Watch not found")
+	}
+	return n, nil
+}
+
+func GetWatches(watcher string) []Watch {
+	return queryWatches("SELECT watcher, number, dm_jid, session FROM watches WHERE watcher = $1 ORDER BY number", watcher)
+}
+
+func GetWatchersForNumber(number string) []Watch {
+	return queryWatches("SELECT watcher, number, dm_jid, session FROM watches WHERE number = $1 ORDER BY watcher", number)
+}
+
+func queryWatches(query string, arg string) []Watch {
+	list := []Watch{}
+	rows, err := db.Query(query, arg)
+	if err != nil {
+		return list
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var w Watch
+		if rows.Scan(&w.Watcher, &w.Number, &w.DMJID, &w.Session) == nil {
+			list = append(list, w)
+		}
+	}
+	return list
+}
+
+// --- OTP History ---
+
+type OTPRecord struct {
+	MsgID     string    `json:"msg_id"`
+	Source    int       `json:"source"`
+	Country   string    `json:"country"`
+	Phone     string    `json:"phone"`
+	Service   string    `json:"service"`
+	Code      string    `json:"code"`
+	Body      string    `json:"body"`
+	RawTime   string    `json:"raw_time"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func SaveOTPRecord(r OTPRecord) {
+	db.Exec(`INSERT INTO otp_history (msg_id, source, country, phone, service, code, body, raw_time, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT DO NOTHING`,
+		r.MsgID, r.Source, r.Country, r.Phone, r.Service, r.Code, r.Body, r.RawTime, r.CreatedAt)
+}
+
+// FindOTPRecords مکمل نمبر یا آخری ہندسوں (suffix) سے تازہ ترین ریکارڈز
+func FindOTPRecords(number string, limit int) []OTPRecord {
+	return queryOTPRecords(`SELECT msg_id, source, country, phone, service, code, body, raw_time, created_at
+		FROM otp_history WHERE phone = $1 OR phone LIKE $2 ORDER BY created_at DESC LIMIT $3`,
+		number, "%"+number, limit)
+}
+
+// FindWatchedOTPRecords وہی، مگر صرف ان نمبرز کے جو watcher watch کر رہا ہے
+func FindWatchedOTPRecords(watcher, number string, limit int) []OTPRecord {
+	return queryOTPRecords(`SELECT msg_id, source, country, phone, service, code, body, raw_time, created_at
+		FROM otp_history WHERE (phone = $1 OR phone LIKE $2)
+		AND phone IN (SELECT number FROM watches WHERE watcher = $3)
+		ORDER BY created_at DESC LIMIT $4`,
+		number, "%"+number, watcher, limit)
+}
+
+func queryOTPRecords(query string, args ...interface{}) []OTPRecord {
+	list := []OTPRecord{}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return list
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r OTPRecord
+		if rows.Scan(&r.MsgID, &r.Source, &r.Country, &r.Phone, &r.Service, &r.Code, &r.Body, &r.RawTime, &r.CreatedAt) == nil {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+// --- API Tokens ---
+
+// SetAPIToken پرانا ٹوکن (اگر ہو) بدل دیتا ہے
+func SetAPIToken(owner, token string) error {
+	_, err := db.Exec(`INSERT INTO api_tokens (token, owner, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT(owner) DO UPDATE SET token = excluded.token, created_at = excluded.created_at`,
+		token, owner, time.Now())
+	return err
+}
+
+func GetTokenOwner(token string) (string, bool) {
+	var owner string
+	err := db.QueryRow("SELECT owner FROM api_tokens WHERE token = $1", token).Scan(&owner)
+	return owner, err == nil
+}
+
+// --- Source Subscriptions ---
+
+// SourceSubs channel -> subscribed sources ("" = user-level)
+// اگر کسی لیول پر کوئی entry نہ ہو تو سب sources allowed ہیں
+type SourceSubs map[string]map[int]bool
+
+// Allows پہلے چینل لیول، پھر user لیول، ورنہ سب allowed
+func (s SourceSubs) Allows(channel string, source int) bool {
+	if set, ok := s[channel]; ok && len(set) > 0 {
+		return set[source]
+	}
+	if set, ok := s[""]; ok && len(set) > 0 {
+		return set[source]
+	}
+	return true
+}
+
+// Effective کسی لیول پر اصل allowed sources
+func (s SourceSubs) Effective(channel string) []int {
+	list := []int{}
+	for i := 1; i <= len(Config.OTPApiURLs); i++ {
+		if s.Allows(channel, i) {
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+func GetSourceSubs(owner string) SourceSubs {
+	return sourceSubsCache.get(owner, func() (SourceSubs, error) { return loadSourceSubs(db, owner) })
+}
+
+func loadSourceSubs(q queryer, owner string) (SourceSubs, error) {
+	subs := SourceSubs{}
+	rows, err := q.Query("SELECT channel, source FROM source_subs WHERE owner = $1", owner)
+	if err != nil {
+		return subs, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ch string
+		var src int
+		if rows.Scan(&ch, &src) == nil {
+			if subs[ch] == nil {
+				subs[ch] = map[int]bool{}
+			}
+			subs[ch][src] = true
+		}
+	}
+	return subs, rows.Err()
+}
+
+// UpdateSourceSubs موجودہ subs پڑھ کر update سے نئی لسٹ لیتا ہے اور اسی ٹرانزیکشن میں
+// لیول کی پوری لسٹ بدل دیتا ہے، تاکہ ایک ساتھ آنے والی کمانڈز ایک دوسرے کو overwrite نہ کریں
+func UpdateSourceSubs(owner, channel string, update func(SourceSubs) ([]int, error)) ([]int, error) {
+	var sources []int
+	err := withTx("source_subs:"+owner, func(tx *sql.Tx) error {
+		current, err := loadSourceSubs(tx, owner)
+		if err != nil {
+			return err
+		}
+		if sources, err = update(current); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM source_subs WHERE owner = $1 AND channel = $2", owner, channel); err != nil {
+			return err
+		}
+		for _, src := range sources {
+			if _, err := tx.Exec("INSERT INTO source_subs (owner, channel, source) VALUES ($1, $2, $3)", owner, channel, src); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sourceSubsCache.invalidate(owner)
+	return sources, err
+}
+
+// --- Webhooks ---
+
+type Webhook struct {
+	ID     int64
+	Owner  string
+	URL    string
+	Secret string
+}
+
+func AddWebhook(owner, url, secret string) (int64, error) {
+	// LastInsertId پوسٹگریس پر نہیں چلتا، اس لیے RETURNING
+	var id int64
+	err := db.QueryRow("INSERT INTO webhooks (owner, url, secret, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING RETURNING id",
+		owner, url, secret, time.Now()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("Webhook already added")
+	}
+	return id, err
+}
+
+func RemoveWebhook(owner string, id int64) error {
+	res, err := db.Exec("DELETE FROM webhooks WHERE owner = $1 AND id = $2", owner, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("Webhook not found")
+	}
+	return nil
+}
+
+// GetWebhooks owner خالی ہو تو سب
+func GetWebhooks(owner string) []Webhook {
+	list := []Webhook{}
+	query := "SELECT id, owner, url, secret FROM webhooks WHERE owner = $1 ORDER BY id"
+	args := []interface{}{owner}
+	if owner == "" {
+		query = "SELECT id, owner, url, secret FROM webhooks ORDER BY id"
+		args = nil
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return list
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var w Webhook
+		if rows.Scan(&w.ID, &w.Owner, &w.URL, &w.Secret) == nil {
+			list = append(list, w)
+		}
+	}
+	return list
+}
+
+func LogWebhookAttempt(webhookID int64, event string, attempt, statusCode int, sendErr error) {
+	errText := ""
+	if sendErr != nil {
+		errText = sendErr.Error()
+	}
+	db.Exec("INSERT INTO webhook_log (webhook_id, event, attempt, status_code, error, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		webhookID, event, attempt, statusCode, errText, time.Now())
+}
+
+// --- External Sinks ---
+
+type Sink struct {
+	ID       int64
+	Owner    string
+	Kind     string // telegram | discord
+	Target   string // Telegram chat_id یا Discord webhook URL
+	Token    string // Telegram bot token
+	Template string
+}
+
+func AddSink(s Sink) (int64, error) {
+	var id int64
+	err := db.QueryRow("INSERT INTO sinks (owner, kind, target, token, template, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		s.Owner, s.Kind, s.Target, s.Token, s.Template, time.Now()).Scan(&id)
+	return id, err
+}
+
+func RemoveSink(owner string, id int64) error {
+	res, err := db.Exec("DELETE FROM sinks WHERE owner = $1 AND id = $2", owner, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("Sink not found")
+	}
+	return nil
+}
+
+func SetSinkTemplate(owner string, id int64, template string) error {
+	res, err := db.Exec("UPDATE sinks SET template = $1 WHERE owner = $2 AND id = $3", template, owner, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("Sink not found")
+	}
+	return nil
+}
+
+// GetSinks owner خالی ہو تو سب
+func GetSinks(owner string) []Sink {
+	list := []Sink{}
+	query := "SELECT id, owner, kind, target, token, template FROM sinks WHERE owner = $1 ORDER BY id"
+	args := []interface{}{owner}
+	if owner == "" {
+		query = "SELECT id, owner, kind, target, token, template FROM sinks ORDER BY id"
+		args = nil
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return list
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s Sink
+		if rows.Scan(&s.ID, &s.Owner, &s.Kind, &s.Target, &s.Token, &s.Template) == nil {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// --- Channel Schedules ---
+
+// scheduleColumns channels ٹیبل کے per-channel شیڈول کالمز (mode NULL = کوئی شیڈول نہیں)
+const scheduleColumns = "COALESCE(days, ''), COALESCE(start_hour, 0), COALESCE(end_hour, 0), COALESCE(tz, 'UTC'), mode"
+
+type Schedule struct {
+	Owner     string
+	Channel   string
+	Days      string // "mon,tue,wed" (خالی = ہر دن)
+	StartHour int
+	EndHour   int
+	TZ        string
+	Mode      string // drop | hold
+}
+
+// SetSchedule "drop" موڈ میں digest job اس چینل کو نہیں دیکھتا، اس لیے پہلے سے held OTPs
+// اسی ٹرانزیکشن میں ختم (واپسی: کتنے discard ہوئے)
+func SetSchedule(s Schedule) (int64, error) {
+	var discarded int64
+	err := withTx("schedule:"+s.Owner, func(tx *sql.Tx) error {
+		// شیڈول channels کی row کے کالمز میں ہے، اس لیے چینل پہلے active ہونا چاہیے
+		res, err := tx.Exec("UPDATE channels SET days = $1, start_hour = $2, end_hour = $3, tz = $4, mode = $5 WHERE owner = $6 AND jid = $7",
+			s.Days, s.StartHour, s.EndHour, s.TZ, s.Mode, s.Owner, s.Channel)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("Channel not active, use .active first")
+		}
+		if s.Mode != "drop" {
+			return nil
+		}
+		discarded, err = deleteHeldOTPs(tx, s.Owner, s.Channel)
+		return err
+	})
+	scheduleCache.invalidate(s.Owner + "|" + s.Channel)
+	return discarded, err
+}
+
+// RemoveSchedule شیڈول کے بغیر digest کبھی نہیں چلے گا، اس لیے held OTPs بھی ساتھ ختم
+func RemoveSchedule(owner, channel string) (int64, error) {
+	var discarded int64
+	err := withTx("schedule:"+owner, func(tx *sql.Tx) error {
+		res, err := tx.Exec(`UPDATE channels SET days = NULL, start_hour = NULL, end_hour = NULL, tz = NULL, mode = NULL
+			WHERE owner = $1 AND jid = $2 AND mode IS NOT NULL`, owner, channel)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("Schedule not found")
+		}
+		discarded, err = deleteHeldOTPs(tx, owner, channel)
+		return err
+	})
+	scheduleCache.invalidate(owner + "|" + channel)
+	return discarded, err
+}
+
+func deleteHeldOTPs(tx *sql.Tx, owner, channel string) (int64, error) {
+	res, err := tx.Exec("DELETE FROM held_otps WHERE owner = $1 AND channel = $2", owner, channel)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetSchedules owner خالی ہو تو سب
+func GetSchedules(owner string) []Schedule {
+	list := []Schedule{}
+	query := "SELECT owner, jid, " + scheduleColumns + " FROM channels WHERE mode IS NOT NULL AND owner = $1 ORDER BY jid"
+	args := []interface{}{owner}
+	if owner == "" {
+		query = "SELECT owner, jid, " + scheduleColumns + " FROM channels WHERE mode IS NOT NULL ORDER BY owner, jid"
+		args = nil
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return list
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s Schedule
+		if rows.Scan(&s.Owner, &s.Channel, &s.Days, &s.StartHour, &s.EndHour, &s.TZ, &s.Mode) == nil {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func GetSchedule(owner, channel string) (Schedule, bool) {
+	c := scheduleCache.get(owner+"|"+channel, func() (cachedSchedule, error) {
+		s := Schedule{Owner: owner, Channel: channel}
+		err := db.QueryRow("SELECT "+scheduleColumns+" FROM channels WHERE mode IS NOT NULL AND owner = $1 AND jid = $2", owner, channel).
+			Scan(&s.Days, &s.StartHour, &s.EndHour, &s.TZ, &s.Mode)
+		if err == sql.ErrNoRows {
+			return cachedSchedule{s, false}, nil
+		}
+		return cachedSchedule{s, err == nil}, err
+	})
+	return c.sched, c.ok
+}
+
+// --- Held OTPs (Digest) ---
+
+type HeldOTP struct {
+	ID        int64
+	MsgID     string
+	Body      string
+	Attempts  int // ناکام digest sends
+	CreatedAt time.Time
+}
+
+func HoldOTP(owner, channel, msgID, body string) {
+	db.Exec("INSERT INTO held_otps (owner, channel, msg_id, body, created_at) VALUES ($1, $2, $3, $4, $5)",
+		owner, channel, msgID, body, time.Now())
+}
+
+func GetHeldOTPs(owner, channel string) []HeldOTP {
+	list := []HeldOTP{}
+	rows, err := db.Query("SELECT id, msg_id, body, COALESCE(attempts, 0), created_at FROM held_otps WHERE owner = $1 AND channel = $2 ORDER BY id", owner, channel)
+	if err != nil {
+		return list
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h HeldOTP
+		if rows.Scan(&h.ID, &h.MsgID, &h.Body, &h.Attempts, &h.CreatedAt) == nil {
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
+func DeleteHeldOTPs(ids []int64) {
+	for _, id := range ids {
+		db.Exec("DELETE FROM held_otps WHERE id = $1", id)
+	}
+}
+
+// BumpHeldAttempts digest ناکام ہونے پر
+func BumpHeldAttempts(ids []int64) {
+	for _, id := range ids {
+		db.Exec("UPDATE held_otps SET attempts = COALESCE(attempts, 0) + 1 WHERE id = $1", id)
+	}
+}
+
+func CountHeldOTPs(owner, channel string) int {
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM held_otps WHERE owner = $1 AND channel = $2", owner, channel).Scan(&n)
+	return n
+}
+
+// --- Command Prefix ---
+
+func GetPrefix(session string) string {
+	return prefixCache.get(session, func() (string, error) {
+		prefix := CommandPrefix
+		err := db.QueryRow("SELECT prefix FROM session_prefixes WHERE session = $1", session).Scan(&prefix)
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+		return prefix, err
+	})
+}
+
+// SetPrefix خالی prefix = ڈیفالٹ پر واپس
+func SetPrefix(session, prefix string) error {
+	var err error
+	if prefix == "" {
+		_, err = db.Exec("DELETE FROM session_prefixes WHERE session = $1", session)
+	} else {
+		_, err = db.Exec(`INSERT INTO session_prefixes (session, prefix) VALUES ($1, $2)
+			ON CONFLICT(session) DO UPDATE SET prefix = excluded.prefix`, session, prefix)
+	}
+	prefixCache.invalidate(session)
+	return err
+}
+
+// --- Session Members (RBAC) ---
+
+type Member struct {
+	Member string
+	Role   string // admin | viewer
+}
+
+// SetMember نیا ساتھی یا موجودہ کا role بدلنا
+func SetMember(session, member, role string) error {
+	_, err := db.Exec(`INSERT INTO session_members (session, member, role, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT(session, member) DO UPDATE SET role = excluded.role`, session, member, role, time.Now())
+	membersCache.invalidate(session)
+	return err
+}
+
+// RemoveMember صرف اسی role والا ہٹے (.viewer remove کسی admin کو نہ ہٹائے)
+func RemoveMember(session, member, role string) error {
+	res, err := db.Exec("DELETE FROM session_members WHERE session = $1 AND member = $2 AND role = $3", session, member, role)
+	if err != nil {
+		return err
+	}
+	membersCache.invalidate(session)
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s is not a %s", member, role)
+	}
+	return nil
+}
+
+func GetMembers(session string) []Member {
+	return membersCache.get(session, func() ([]Member, error) {
+		list := []Member{}
+		rows, err := db.Query("SELECT member, role FROM session_members WHERE session = $1 ORDER BY created_at, member", session)
+		if err != nil {
+			return list, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var m Member
+			if rows.Scan(&m.Member, &m.Role) == nil {
+				list = append(list, m)
+			}
+		}
+		return list, rows.Err()
+	})
 }
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStores عارضی ڈائریکٹری میں دونوں SQLite ڈیٹابیس کھولتا ہے
+func testStores(t *testing.T) {
+	t.Helper()
+	if DatabaseURL != "" {
+		t.Skip("SQLite only")
+	}
+	dir := t.TempDir()
+	oldBot, oldSess := dbPath, sessionsDBPath
+	dbPath = filepath.Join(dir, "kami_bot.db")
+	sessionsDBPath = filepath.Join(dir, "kami_sessions.db")
+	if err := openStores(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		restoreGate.Lock()
+		closeStores()
+		restoreGate.Unlock()
+		dbPath, sessionsDBPath = oldBot, oldSess
+		resetCaches()
+	})
+}
+
+// testFeed لوکل OTP API جو ہر poll پر یہی rows دیتا ہے
+func testFeed(t *testing.T, rows ...[]interface{}) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"aaData":[`)
+		for i, row := range rows {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `["%v","%v","%v","%v","%v"]`, row...)
+		}
+		fmt.Fprint(w, `]}`)
+	}))
+	t.Cleanup(srv.Close)
+	old := Config.OTPApiURLs
+	Config.OTPApiURLs = []string{srv.URL}
+	t.Cleanup(func() { Config.OTPApiURLs = old })
+}
+
+func TestConcurrentAddChannel(t *testing.T) {
+	testStores(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := AddChannel("923001234567", fmt.Sprintf("1203630%02d@newsletter", i)); err != nil {
+				t.Error(err)
+			}
+			GetUserSettings("923001234567")
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(GetUserSettings("923001234567").Channels); got != 20 {
+		t.Fatalf("channels = %d, want 20", got)
+	}
+}
+
+func TestConcurrentUpdateSourceSubs(t *testing.T) {
+	testStores(t)
+
+	var wg sync.WaitGroup
+	for src := 1; src <= 10; src++ {
+		wg.Add(1)
+		go func(src int) {
+			defer wg.Done()
+			_, err := UpdateSourceSubs("923001234567", "", func(s SourceSubs) ([]int, error) {
+				list := []int{src}
+				for prev := range s[""] {
+					list = append(list, prev)
+				}
+				return list, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(src)
+	}
+	wg.Wait()
+
+	if got := GetSourceSubs("923001234567")[""]; len(got) != 10 {
+		t.Fatalf("sources = %v, want all 10 (lost update)", got)
+	}
+}
+
+func TestConcurrentMarkOTPSent(t *testing.T) {
+	testStores(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		id := fmt.Sprintf("92300%d_2026", i)
+		go func() { defer wg.Done(); MarkOTPSent(id) }()
+		go func() { defer wg.Done(); IsOTPSent(id) }()
+	}
+	wg.Wait()
+
+	sentFingerprints.reset()
+	for i := 0; i < 50; i++ {
+		if id := fmt.Sprintf("92300%d_2026", i); !IsOTPSent(id) {
+			t.Fatalf("%s not recorded", id)
+		}
+	}
+}
+
+func TestIsOTPSentOnClosedDB(t *testing.T) {
+	testStores(t)
+	db.Close()
+	defer openBotDB()
+
+	// DB دستیاب نہ ہو تو OTP روکا جائے، دوبارہ نہ بھیجا جائے
+	if !IsOTPSent("923001234567_2026") {
+		t.Fatal("IsOTPSent = false on a closed DB, feed would be re-sent")
+	}
+}
+
+func TestRestoreWhilePolling(t *testing.T) {
+	testStores(t)
+	testFeed(t,
+		[]interface{}{"2026-10-19 10:00:00", "Pakistan", "923001111111", "WhatsApp", "Your code 123-456"},
+		[]interface{}{"2026-10-19 10:01:00", "Pakistan", "923002222222", "Telegram", "Code 98765"},
+	)
+
+	if err := AddChannel("923001234567", "120363001@newsletter"); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := CreateBackup("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// backup کے بعد کا ڈیٹا: restore پر غائب ہونا چاہیے
+	if err := AddChannel("923001234567", "120363002@newsletter"); err != nil {
+		t.Fatal(err)
+	}
+	pollSources()
+	if !IsOTPSent("923001111111_2026-10-19 10:00:00") {
+		t.Fatal("feed not marked after poll")
+	}
+
+	// غلط passphrase: کچھ نہیں بدلتا
+	if err := RestoreBackup(archive, "wrong passphrase"); err == nil {
+		t.Fatal("restore with wrong passphrase succeeded")
+	}
+	if got := len(GetUserSettings("923001234567").Channels); got != 2 {
+		t.Fatalf("channels after failed restore = %d, want 2", got)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				pollSources()
+				GetUserSettings("923001234567")
+			}
+		}
+	}()
+
+	err = RestoreBackup(archive, "correct horse")
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := GetUserSettings("923001234567").Channels; len(got) != 1 || got[0] != "120363001@newsletter" {
+		t.Fatalf("channels after restore = %v, want backup's", got)
+	}
+	// backup کی sent_history پرانی ہے، پھر بھی موجودہ فیڈ دوبارہ نہ جائے
+	sentFingerprints.reset()
+	for _, id := range []string{"923001111111_2026-10-19 10:00:00", "923002222222_2026-10-19 10:01:00"} {
+		if !IsOTPSent(id) {
+			t.Fatalf("%s would be re-sent after restore", id)
+		}
+	}
+}
+
+func TestSwapDataFilesUndo(t *testing.T) {
+	dir := t.TempDir()
+	oldBot, oldSess := dbPath, sessionsDBPath
+	dbPath = filepath.Join(dir, "kami_bot.db")
+	sessionsDBPath = filepath.Join(dir, "kami_sessions.db")
+	defer func() { dbPath, sessionsDBPath = oldBot, oldSess }()
+
+	staged := filepath.Join(dir, "staged")
+	os.Mkdir(staged, 0755)
+	for name, path := range backupFiles() {
+		os.WriteFile(path, []byte("old "+name), 0600)
+		os.WriteFile(path+"-wal", []byte("wal"), 0600)
+		os.WriteFile(filepath.Join(staged, name), []byte("new "+name), 0600)
+	}
+
+	undo, err := swapDataFiles(staged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, path := range backupFiles() {
+		if b, _ := os.ReadFile(path); string(b) != "new "+name {
+			t.Fatalf("%s = %q after swap", name, b)
+		}
+		if _, err := os.Stat(path + "-wal"); err == nil {
+			t.Fatalf("%s: stale -wal left next to restored file", name)
+		}
+	}
+
+	if err := undo(); err != nil {
+		t.Fatal(err)
+	}
+	for name, path := range backupFiles() {
+		if b, _ := os.ReadFile(path); string(b) != "old "+name {
+			t.Fatalf("%s = %q after undo", name, b)
+		}
+		if b, _ := os.ReadFile(path + "-wal"); string(b) != "wal" {
+			t.Fatalf("%s: -wal not restored", name)
+		}
+	}
+}
+
+func TestBackupNeedsAccept(t *testing.T) {
+	testStores(t)
+
+	if err := AddBackup("923001234567", "923007654321"); err != nil {
+		t.Fatal(err)
+	}
+	// درخواست زیر التوا: failover میں شامل نہیں
+	if got := GetBackups("923001234567"); len(got) != 0 {
+		t.Fatalf("backups before accept = %v, want none", got)
+	}
+	if links := GetBackupRequests("923007654321"); len(links) != 1 || links[0].Accepted {
+		t.Fatalf("requests = %+v, want one pending", links)
+	}
+	if err := AcceptBackup("923009999999", "923007654321"); err == nil {
+		t.Fatal("accepted a request that was never made")
+	}
+
+	if err := AcceptBackup("923001234567", "923007654321"); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetBackups("923001234567"); len(got) != 1 || got[0] != "923007654321" {
+		t.Fatalf("backups after accept = %v", got)
+	}
+	if err := AcceptBackup("923001234567", "923007654321"); err == nil {
+		t.Fatal("accepted the same request twice")
+	}
+}
+
+func TestSinkDeliveriesNotInSessionStats(t *testing.T) {
+	testStores(t)
+	hourAgo := time.Now().Add(-time.Hour)
+
+	LogDelivery("923001111111_1", "120363001@newsletter", "923001234567", "923001234567", nil)
+	LogSinkDelivery("923001111111_1", "telegram:#1", "923001234567", "telegram", fmt.Errorf("chat not found"))
+	LogSinkDelivery("923001111111_2", "discord:#2", "923001234567", "discord", nil)
+
+	if n := CountDeliveries("923001234567", "failed", hourAgo); n != 0 {
+		t.Fatalf("failed = %d, sink failure counted against the session", n)
+	}
+	if n := CountDeliveries("923001234567", "sent", hourAgo); n != 1 {
+		t.Fatalf("sent = %d, want only the WhatsApp delivery", n)
+	}
+	if last, ok := LastDelivery("923001234567"); !ok || last.Target != "120363001@newsletter" {
+		t.Fatalf("last delivery = %+v, want the WhatsApp one", last)
+	}
+
+	kinds := map[string]int{}
+	for _, d := range SearchDeliveries(OTPFilter{}, "923001234567") {
+		kinds[d.Kind]++
+	}
+	if kinds["whatsapp"] != 1 || kinds["telegram"] != 1 || kinds["discord"] != 1 {
+		t.Fatalf("export kinds = %v", kinds)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 🪝 OUTBOUND WEBHOOKS (Signed, Async, Retried)
+// ---------------------------------------------------------
+
+const (
+	webhookWorkers    = 4
+	webhookQueueSize  = 1000
+	webhookMaxRetries = 3
+
+	// WebhookTolerance receiver اس سے پرانے X-Kami-Timestamp والی request رد کرے (replay سے بچاؤ)
+	WebhookTolerance = 5 * time.Minute
+)
+
+// WebhookEvent وہ JSON جو ہر webhook کو POST ہوتا ہے
+type WebhookEvent struct {
+	Event     string      `json:"event"` // otp | session.paired | session.connected | session.logged_out
+	Timestamp int64       `json:"timestamp"`
+	Session   string      `json:"session,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+type webhookJob struct {
+	hook  Webhook
+	event WebhookEvent
+}
+
+var webhookQueue = make(chan webhookJob, webhookQueueSize)
+
+// StartWebhookWorkers پس منظر workers، WhatsApp ڈیلیوری کو کبھی block نہیں کرتے
+func StartWebhookWorkers() {
+	for i := 0; i < webhookWorkers; i++ {
+		go func() {
+			for job := range webhookQueue {
+				deliverWebhook(job)
+			}
+		}()
+	}
+	fmt.Printf("🪝 Webhook Workers Started (%d)\n", webhookWorkers)
+}
+
+// EmitOTPWebhooks صرف ان مالکوں کے webhooks پر جن کے چینلز تک یہ OTP گیا (owners)،
+// ADMIN_NUMBERS کے webhooks کو subscribed sources کے سب OTPs۔
+// مکمل نمبر صرف canViewNumber والوں کو، باقی کو وہی masked نمبر جو چینل میں جاتا ہے
+func EmitOTPWebhooks(rec OTPRecord, owners map[string]bool) {
+	now := time.Now().Unix()
+	for _, hook := range GetWebhooks("") {
+		admin := isAdmin(hook.Owner)
+		if !owners[hook.Owner] && !(admin && GetSourceSubs(hook.Owner).Allows("", rec.Source)) {
+			continue
+		}
+		phone := rec.Phone
+		if !canViewNumber(hook.Owner, phone) {
+			phone = maskPhoneNumber(phone)
+		}
+		enqueueWebhook(hook, WebhookEvent{
+			Event:     "otp",
+			Timestamp: now,
+			Data: map[string]interface{}{
+				"msg_id":      rec.MsgID,
+				"source":      rec.Source,
+				"source_name": sourceName(rec.Source),
+				"country":     rec.Country,
+				"phone":       phone,
+				"service":     rec.Service,
+				"code":        rec.Code,
+				"body":        rec.Body,
+				"raw_time":    rec.RawTime,
+			},
+		})
+	}
+}
+
+// EmitSessionWebhook سیشن کے مالک کے webhooks پر (paired/connected/logged_out)
+func EmitSessionWebhook(session, event string, data interface{}) {
+	evt := WebhookEvent{
+		Event:     "session." + event,
+		Timestamp: time.Now().Unix(),
+		Session:   session,
+		Data:      data,
+	}
+	for _, hook := range GetWebhooks(session) {
+		enqueueWebhook(hook, evt)
+	}
+}
+
+func enqueueWebhook(hook Webhook, evt WebhookEvent) {
+	select {
+	case webhookQueue <- webhookJob{hook: hook, event: evt}:
+	default:
+		fmt.Printf("⚠️ [WEBHOOK] Queue full, dropped %s for #%d\n", evt.Event, hook.ID)
+		LogWebhookAttempt(hook.ID, evt.Event, 0, 0, fmt.Errorf("queue full"))
+	}
+}
+
+func deliverWebhook(job webhookJob) {
+	body, err := json.Marshal(job.event)
+	if err != nil {
+		return
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	timestamp := strconv.FormatInt(job.event.Timestamp, 10)
+
+	for attempt := 1; attempt <= webhookMaxRetries; attempt++ {
+		req, _ := http.NewRequest("POST", job.hook.URL, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Kami-OTP-Bot-Webhook")
+		req.Header.Set("X-Kami-Event", job.event.Event)
+		req.Header.Set("X-Kami-Timestamp", timestamp)
+		req.Header.Set("X-Kami-Signature", "sha256="+signWebhook(job.hook.Secret, timestamp, body))
+
+		status := 0
+		resp, err := client.Do(req)
+		if err == nil {
+			status = resp.StatusCode
+			resp.Body.Close()
+			if status < 200 || status >= 300 {
+				err = fmt.Errorf("HTTP %d", status)
+			}
+		}
+		LogWebhookAttempt(job.hook.ID, job.event.Event, attempt, status, err)
+
+		if err == nil {
+			return
+		}
+		if attempt < webhookMaxRetries {
+			time.Sleep(time.Duration(attempt*attempt) * 2 * time.Second) // 2s, 8s
+		} else {
+			fmt.Printf("❌ [WEBHOOK] #%d %s failed after %d attempts: %v\n", job.hook.ID, job.event.Event, attempt, err)
+		}
+	}
+}
+
+// signWebhook HMAC-SHA256(secret, timestamp + "." + body) — receiver اسی طرح verify کرے
+// اور WebhookTolerance سے پرانا timestamp رد کرے، تاکہ پکڑی گئی request دوبارہ نہ چلائی جا سکے
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------
+// ⚡ IN-MEMORY CACHES (Delivery Hot Path)
+// ---------------------------------------------------------
+
+// ہر نئے OTP پر ہر سیشن کی settings/subs/backups/schedule پڑھی جاتی ہیں؛
+// یہ کیش DB تک صرف پہلی بار اور کسی تبدیلی کے بعد جاتا ہے۔
+// d.go کے write functions کامیاب write کے بعد متعلقہ key invalidate کرتے ہیں۔
+// invalidate صرف اسی process میں ہوتا ہے: ایک ہی PostgreSQL پر کئی replicas ہوں تو
+// دوسرے replica کی تبدیلی یہاں SETTINGS_CACHE_TTL_SECONDS تک پرانی دکھ سکتی ہے
+
+// settingsCache کیش شدہ ویلیوز (slices/maps) صرف پڑھنے کے لیے ہیں، انہیں بدلیں نہیں
+type settingsCache[T any] struct {
+	mu    sync.RWMutex
+	items map[string]cacheEntry[T]
+	gen   uint64 // ہر invalidate پر بڑھتا ہے تاکہ پرانا load کیش میں نہ بیٹھے
+	ttl   time.Duration
+}
+
+type cacheEntry[T any] struct {
+	v  T
+	at time.Time
+}
+
+func newSettingsCache[T any]() *settingsCache[T] {
+	return &settingsCache[T]{items: map[string]cacheEntry[T]{}, ttl: SettingsCacheTTL}
+}
+
+// get load کی غلطی پر ملی ویلیو (خالی/ڈیفالٹ) واپس کرتا ہے مگر کیش نہیں کرتا،
+// تاکہ DB کی عارضی خرابی کے بعد اگلی کال دوبارہ پڑھے
+func (c *settingsCache[T]) get(key string, load func() (T, error)) T {
+	c.mu.RLock()
+	e, ok := c.items[key]
+	gen := c.gen
+	c.mu.RUnlock()
+	if ok && (c.ttl <= 0 || time.Since(e.at) < c.ttl) {
+		return e.v
+	}
+
+	v, err := load()
+	if err != nil {
+		return v
+	}
+	c.mu.Lock()
+	if c.gen == gen {
+		c.items[key] = cacheEntry[T]{v, time.Now()}
+	}
+	c.mu.Unlock()
+	return v
+}
+
+func (c *settingsCache[T]) invalidate(key string) {
+	c.mu.Lock()
+	delete(c.items, key)
+	c.gen++
+	c.mu.Unlock()
+}
+
+func (c *settingsCache[T]) reset() {
+	c.mu.Lock()
+	c.items = map[string]cacheEntry[T]{}
+	c.gen++
+	c.mu.Unlock()
+}
+
+type cachedSchedule struct {
+	sched Schedule
+	ok    bool
+}
+
+var (
+	userSettingsCache = newSettingsCache[UserSettings]()
+	sourceSubsCache   = newSettingsCache[SourceSubs]()
+	backupsCache      = newSettingsCache[[]string]()
+	scheduleCache     = newSettingsCache[cachedSchedule]() // key: owner|channel
+	prefixCache       = newSettingsCache[string]()
+	membersCache      = newSettingsCache[[]Member]()
+)
+
+// ---------------------------------------------------------
+// 🧾 RECENT FINGERPRINTS (sent_history کے آگے)
+// ---------------------------------------------------------
+
+// fingerprintCache حال ہی میں بھیجے گئے msg IDs؛ بھر جائے تو سب سے پرانا نکلتا ہے
+// (ring buffer)۔ صرف "بھیجا جا چکا" یاد رکھتا ہے، miss پر DB سے پوچھا جاتا ہے
+type fingerprintCache struct {
+	mu   sync.Mutex
+	set  map[string]bool
+	ring []string
+	next int
+}
+
+var sentFingerprints = newFingerprintCache(SentCacheSize)
+
+func newFingerprintCache(size int) *fingerprintCache {
+	return &fingerprintCache{set: make(map[string]bool, size), ring: make([]string, size)}
+}
+
+func (f *fingerprintCache) has(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.set[id]
+}
+
+func (f *fingerprintCache) add(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.set[id] {
+		return
+	}
+	if old := f.ring[f.next]; old != "" {
+		delete(f.set, old)
+	}
+	f.ring[f.next] = id
+	f.set[id] = true
+	f.next = (f.next + 1) % len(f.ring)
+}
+
+func (f *fingerprintCache) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.set = make(map[string]bool, len(f.ring))
+	f.ring = make([]string, len(f.ring))
+	f.next = 0
+}
+
+// resetCaches restore کے بعد (پورا ڈیٹا بدل گیا)
+func resetCaches() {
+	userSettingsCache.reset()
+	sourceSubsCache.reset()
+	backupsCache.reset()
+	scheduleCache.reset()
+	prefixCache.reset()
+	membersCache.reset()
+	sentFingerprints.reset()
+	resetLIDCache()
+}
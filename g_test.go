@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSettingsCacheSkipsLoadErrors(t *testing.T) {
+	c := newSettingsCache[string]()
+	calls := 0
+	load := func(v string, err error) func() (string, error) {
+		return func() (string, error) { calls++; return v, err }
+	}
+
+	if got := c.get("k", load("", errors.New("database is locked"))); got != "" {
+		t.Fatalf("got %q", got)
+	}
+	// غلطی والا نتیجہ کیش نہیں ہوا، اگلی کال دوبارہ پڑھتی ہے
+	if got := c.get("k", load("fresh", nil)); got != "fresh" || calls != 2 {
+		t.Fatalf("got %q after %d loads, want fresh after 2", got, calls)
+	}
+	if got := c.get("k", load("other", nil)); got != "fresh" || calls != 2 {
+		t.Fatalf("got %q after %d loads, want cached fresh", got, calls)
+	}
+}
+
+func TestSettingsCacheTTL(t *testing.T) {
+	c := newSettingsCache[string]()
+	c.ttl = 20 * time.Millisecond
+
+	c.get("k", func() (string, error) { return "old", nil })
+	// دوسرے replica نے DB بدلا: TTL کے بعد نئی ویلیو
+	time.Sleep(30 * time.Millisecond)
+	if got := c.get("k", func() (string, error) { return "new", nil }); got != "new" {
+		t.Fatalf("got %q after TTL, want new", got)
+	}
+}
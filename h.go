@@ -3,17 +3,35 @@ package main
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"google.golang.org/protobuf/proto"
 )
 
-func EventHandler(cli *whatsmeow.Client) func(interface{}) {
+// EventHandler session: کلائنٹ بناتے وقت کا صاف نمبر؛ logout کے وقت cli.Store.ID
+// پر بھروسہ نہیں (whatsmeow اسے nil کر دیتا ہے)
+func EventHandler(cli *whatsmeow.Client, session string) func(interface{}) {
 	return func(evt interface{}) {
+		handleLIDEvent(cli, session, evt)
+		trackSessionState(session, evt)
 		switch v := evt.(type) {
+		case *events.PairSuccess:
+			EmitSessionWebhook(getCleanID(v.ID.User), "paired", map[string]string{
+				"jid":      v.ID.String(),
+				"platform": v.Platform,
+			})
+		case *events.Connected:
+			EmitSessionWebhook(session, "connected", nil)
+		case *events.LoggedOut:
+			EmitSessionWebhook(session, "logged_out", map[string]string{
+				"reason": v.Reason.String(),
+			})
 		case *events.Message:
 			if !v.Info.IsFromMe {
 				// Self-ignore removed so users can control their own bot via their own number if needed, 
@@ -27,95 +45,967 @@ func EventHandler(cli *whatsmeow.Client) func(interface{}) {
 }
 
 func handleCommands(cli *whatsmeow.Client, evt *events.Message) {
-	msgText := ""
-	if evt.Message.GetConversation() != "" {
-		msgText = evt.Message.GetConversation()
-	} else if evt.Message.ExtendedTextMessage != nil {
-		msgText = evt.Message.ExtendedTextMessage.GetText()
+	if cli.Store.ID == nil {
+		return
 	}
+	restoreGate.RLock()
+	defer restoreGate.RUnlock()
 
-	args := strings.Fields(msgText)
-	if len(args) == 0 {
+	// text، extended text یا media caption؛ reply ہو تو quoted متن بھی
+	text, quoted := messageText(evt.Message)
+	session := getCleanID(cli.Store.ID.User)
+	prefix := GetPrefix(session)
+	name, rest, ok := parseCommand(text, prefix)
+	if !ok {
+		return
+	}
+	cmd, ok := commandIndex[name]
+	if !ok {
 		return
 	}
 
-	cmd := strings.ToLower(args[0])
-	
+	args := strings.Fields(rest)
+	// quoted متن صرف تب جب بھیجنے والے نے خود کچھ نہ لکھا ہو، اور وہ ایک ہی ویلیو ہو
+	if cmd.Quoted && len(args) == 0 {
+		if v := strings.Fields(quoted); len(v) == 1 {
+			args, rest = v, v[0]
+		}
+	}
+
 	// 🔥 OLD CODE:
 	// fullJID := cli.Store.ID.ToNonAD().String()
 	// userJID := getCleanID(fullJID)
 
 	// ✨ NEW LID FIX:
 	// 1. آنے والے میسج کا Sender چیک کریں
-	senderJID := evt.Info.Sender.ToNonAD().String()
-	
 	// 2. LID System سے پوچھیں کہ اس کا اصلی نمبر کیا ہے؟
-	// اگر یہ بوٹ خود ہے (Note to self) تو `cli.Store.ID` یوز ہوگا، ورنہ Sender
-	targetJID := senderJID
-	if evt.Info.IsFromMe {
-		targetJID = cli.Store.ID.ToNonAD().String()
+	// (Note to self → بوٹ خود؛ گروپ میں LID → SenderAlt یا whatsmeow کا LID اسٹور)
+	// settings ہمیشہ سیشن (مالک) کی: delegated admins/viewers اپنی الگ rows نہیں بناتے
+	user := ResolveSender(cli, evt)
+	c := &CommandContext{
+		Cli:     cli,
+		Evt:     evt,
+		Cmd:     cmd,
+		Prefix:  prefix,
+		Args:    args,
+		Text:    rest,
+		Sender:  evt.Info.Sender.ToNonAD().String(),
+		User:    user,
+		Session: session,
+		Role:    commandRole(session, user, evt.Info.IsFromMe),
 	}
-	
-	// 3. Resolve کریں (LID -> Phone)
-	userJID := ResolveJID(targetJID)
 
 	// ڈیبگ لاگ (تاکہ پتہ چلے کنورژن ہو رہی ہے)
-	// fmt.Printf("🤖 Command from: %s (Resolved to: %s)\n", targetJID, userJID)
+	// fmt.Printf("🤖 Command %s from: %s (Resolved to: %s)\n", cmd.Name, c.Sender, c.User)
+
+	// 🔐 RBAC: اجازت نہیں تو خاموشی سے نظرانداز (جواب نہیں، تاکہ کوئی بوٹ کو probe نہ کرے)
+	if c.Role < cmd.Role {
+		fmt.Printf("🚫 [RBAC] %s (%s) denied %s%s on session %s in %s\n",
+			maskPhoneNumber(user), c.Role, prefix, cmd.Name, session, evt.Info.Chat.ToNonAD().String())
+		return
+	}
+
+	if len(args) < cmd.MinArgs {
+		c.Usage()
+		return
+	}
+	cmd.Run(c)
+}
+
+// ---------------------------------------------------------
+// 📋 COMMANDS (رجسٹری کی ترتیب = .help کی ترتیب)
+// ---------------------------------------------------------
+
+func init() {
+	registerCommands(
+		&Command{Name: "help", Aliases: []string{"menu", "commands"}, Usage: []string{"[command]"},
+			Desc: "List commands or show help for one", Role: RoleViewer, Run: cmdHelp},
+		&Command{Name: "id", Aliases: []string{"jid"},
+			Desc: "Show your ID and this chat's ID", Role: RoleViewer, Run: cmdID},
+		&Command{Name: "status", Aliases: []string{"ping"},
+			Desc: "Session connection, deliveries and source health", Role: RoleViewer, Run: cmdStatus},
+		&Command{Name: "list", Aliases: []string{"channels"},
+			Desc: "Show active channels and footer link", Role: RoleViewer, Run: cmdList},
+		&Command{Name: "active", Aliases: []string{"activate"}, Usage: []string{"[Channel_ID|Group/Channel_Link] [join]"},
+			Desc: "Start sending OTPs to this chat or a given group/channel", Role: RoleAdmin, Quoted: true, Run: cmdActive},
+		&Command{Name: "deactive", Aliases: []string{"deactivate"}, Usage: []string{"[Channel_ID|Group/Channel_Link]"},
+			Desc: "Stop sending OTPs to this chat or a given group/channel", Role: RoleAdmin, Quoted: true, Run: cmdDeactive},
+		&Command{Name: "change", Aliases: []string{"link"}, Usage: []string{"<New_Link>"}, MinArgs: 1,
+			Desc: "Change the footer link on OTP messages", Role: RoleAdmin, Quoted: true, Run: cmdChange},
+		&Command{Name: "sources", Aliases: []string{"source"}, Usage: []string{"[Channel_ID]", "subscribe|unsubscribe <name> [Channel_ID]"},
+			Desc: "Show or change which OTP sources you receive", Role: RoleAdmin, Run: cmdSources},
+		&Command{Name: "schedule", Usage: []string{"", "<Channel_ID|here> <days> <HH-HH> <Timezone> [drop|hold]", "<Channel_ID|here> off"},
+			Desc: "Limit a channel to opening hours (e.g. here mon-fri 09-18 Asia/Karachi hold)", Role: RoleAdmin, Run: cmdSchedule},
+		&Command{Name: "backup", Usage: []string{"add|remove <Number>", "accept|reject <Owner_Number>", "list"}, MinArgs: 1,
+			Desc: "Failover sessions that send when this one is down", Role: RoleAdmin, Run: cmdBackup},
+		&Command{Name: "webhook", Usage: []string{"add <URL>", "remove <ID>", "list"}, MinArgs: 1,
+			Desc: "Signed HTTP callbacks for OTP and session events", Role: RoleAdmin, Run: cmdWebhook},
+		&Command{Name: "sink", Usage: []string{"add telegram <Bot_Token> <Chat_ID>", "add discord <Webhook_URL>", "template <ID> <Text>", "remove <ID>", "list"}, MinArgs: 1,
+			Desc: "Forward OTPs to Telegram or Discord", Role: RoleAdmin, Run: cmdSink},
+		&Command{Name: "watch", Usage: []string{"<Number> [Session]"}, MinArgs: 1,
+			Desc: "Send a number's OTPs privately to you or a session (bot admins only)", Role: RoleOwner, Run: cmdWatch},
+		&Command{Name: "unwatch", Usage: []string{"<Number>", "all"}, MinArgs: 1,
+			Desc: "Stop watching a number", Role: RoleAdmin, Quoted: true, Run: cmdUnwatch},
+		&Command{Name: "watches", Aliases: []string{"watchlist"},
+			Desc: "List watched numbers", Role: RoleViewer, Run: cmdWatches},
+		&Command{Name: "otp", Usage: []string{"<Number or last 6 digits>"}, MinArgs: 1,
+			Desc: "Recent OTPs for a watched number", Role: RoleViewer, Quoted: true, Run: cmdOTP},
+		&Command{Name: "search", Aliases: []string{"find"}, Usage: []string{"<text> [country:x] [service:y] [source:z] [phone:digits] [page:n]"}, MinArgs: 1,
+			Desc: "Search OTP history", Role: RoleViewer, Run: cmdSearch},
+		&Command{Name: "export", Usage: []string{"[otp|deliveries] [csv|json] [from:YYYY-MM-DD] [to:YYYY-MM-DD] [country:x] [service:y] [source:z]"},
+			Desc: "Download OTP or delivery history as a file", Role: RoleViewer, Run: cmdExport},
+		&Command{Name: "whois", Aliases: []string{"who"}, Usage: []string{"<JID|Number>"}, MinArgs: 1,
+			Desc: "Show LID / phone mapping for a user", Role: RoleViewer, Quoted: true, Run: cmdWhois},
+		&Command{Name: "token",
+			Desc: "Create an HTTP API token (private chat only)", Role: RoleOwner, Run: cmdToken},
+		&Command{Name: "prefix", Usage: []string{"[symbol]", "reset"},
+			Desc: "Show or change this session's command prefix", Role: RoleOwner, Run: cmdPrefix},
+		&Command{Name: "admin", Aliases: []string{"admins"}, Usage: []string{"add|remove <Number>", "list"}, MinArgs: 1,
+			Desc: "Let staff manage this session's channels, link and sources", Role: RoleOwner, Run: cmdMembers("admin")},
+		&Command{Name: "viewer", Aliases: []string{"viewers"}, Usage: []string{"add|remove <Number>", "list"}, MinArgs: 1,
+			Desc: "Let staff view channels and OTP history without changing anything", Role: RoleOwner, Run: cmdMembers("viewer")},
+	)
+}
+
+// cmdMembers .viewer (اور .admin) add|remove <number> | list
+func cmdMembers(role string) func(c *CommandContext) {
+	return func(c *CommandContext) {
+		action := strings.ToLower(c.Args[0])
+		switch action {
+		case "list":
+			msg := fmt.Sprintf("👥 *%ss:*\n", strings.ToUpper(role[:1])+role[1:])
+			count := 0
+			for _, m := range GetMembers(c.Session) {
+				if m.Role == role {
+					count++
+					msg += fmt.Sprintf("%d. `%s`\n", count, m.Member)
+				}
+			}
+			if count == 0 {
+				msg += "None set."
+			}
+			c.Reply(msg)
+
+		case "add", "remove":
+			if len(c.Args) < 2 {
+				c.Reply("❌ Usage: " + c.Prefix + c.Cmd.Name + " " + action + " <Number>")
+				return
+			}
+			member := getCleanID(normalizeNumber(c.Args[1]))
+			if !isDigits(member) {
+				c.Reply("⚠️ Error: Invalid number")
+				return
+			}
+			if member == c.Session {
+				c.Reply("⚠️ Error: " + member + " is this session's owner")
+				return
+			}
+			var err error
+			if action == "add" {
+				err = SetMember(c.Session, member, role)
+			} else {
+				err = RemoveMember(c.Session, member, role)
+			}
+			if err != nil {
+				c.Reply("⚠️ Error: " + err.Error())
+			} else if action == "add" {
+				c.Reply(fmt.Sprintf("✅ %s is now a %s of this session", member, role))
+			} else {
+				c.Reply(fmt.Sprintf("✅ %s removed as %s", member, role))
+			}
+
+		default:
+			c.Usage()
+		}
+	}
+}
+
+func cmdID(c *CommandContext) {
+	chat := c.Evt.Info.Chat.ToNonAD().String()
+	msg := fmt.Sprintf("👤 *User:* `%s`\n📍 *Chat:* `%s`", c.Sender, chat)
+	if owners := GetChannelOwners(chat); len(owners) > 0 {
+		msg += fmt.Sprintf("\n📡 *Active for:* %d session(s)", len(owners))
+	}
+	c.Reply(msg)
+}
+
+// .whois <jid|number> → LID/نمبر کی mapping (ڈیبگ)
+func cmdWhois(c *CommandContext) {
+	c.Reply(whoisJID(c.Args[0], c.Session, isAdmin(c.User)))
+}
 
-	switch cmd {
-	case ".id":
-		sender := evt.Info.Sender.ToNonAD().String()
-		chat := evt.Info.Chat.ToNonAD().String()
-		reply(cli, evt, fmt.Sprintf("👤 *User:* `%s`\n📍 *Chat:* `%s`", sender, chat))
+// .active            → current chat
+// .active <ID|Link>  → resolve link to JID
+// .active <Link> join → join first, then activate
+func cmdActive(c *CommandContext) {
+	channelID, err := resolveTarget(c.Cli, c.Evt, c.Args)
+	if err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+		return
+	}
+	err = AddChannel(c.Session, channelID)
+	if err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+	} else {
+		c.Reply("✅ Channel Activated!\nMessages will now flow to: " + channelID)
+	}
+}
+
+func cmdDeactive(c *CommandContext) {
+	channelID, err := resolveTarget(c.Cli, c.Evt, c.Args)
+	if err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+		return
+	}
+	err = RemoveChannel(c.Session, channelID)
+	if err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+	} else {
+		c.Reply("✅ Channel Deactivated!")
+	}
+}
 
-	case ".active":
-		if len(args) < 2 {
-			reply(cli, evt, "❌ Usage: .active <Channel_ID>")
+func cmdChange(c *CommandContext) {
+	newLink := c.Args[0]
+	SetCustomLink(c.Session, newLink)
+	c.Reply("✅ Footer Link Updated!\nNew Link: " + newLink)
+}
+
+// .backup add <number> | .backup remove <number> | .backup list
+func cmdBackup(c *CommandContext) {
+	action := strings.ToLower(c.Args[0])
+	switch action {
+	case "list":
+		msg := "🔁 *Backup Sessions:*\n"
+		links := GetBackupLinks(c.Session)
+		if len(links) == 0 {
+			msg += "No backups set.\n"
+		}
+		for i, l := range links {
+			state := "⏳ Waiting for accept"
+			if l.Accepted {
+				state = "🔴 Down"
+				if cli := loadedClient(l.Backup); cli != nil && cli.IsConnected() && cli.IsLoggedIn() {
+					state = "🟢 Online"
+				}
+			}
+			msg += fmt.Sprintf("%d. `%s` %s\n", i+1, l.Backup, state)
+		}
+		if reqs := GetBackupRequests(c.Session); len(reqs) > 0 {
+			msg += "\n🛟 *Backup For:*\n"
+			for _, l := range reqs {
+				state := "✅ Accepted"
+				if !l.Accepted {
+					state = "⏳ Pending (" + c.Prefix + "backup accept " + l.Owner + ")"
+				}
+				msg += fmt.Sprintf("• `%s` %s\n", l.Owner, state)
+			}
+		}
+		c.Reply(strings.TrimSpace(msg))
+
+	case "add", "remove":
+		if len(c.Args) < 2 {
+			c.Reply("❌ Usage: " + c.Prefix + "backup " + action + " <Number>")
+			return
+		}
+		backup := getCleanID(normalizeNumber(c.Args[1]))
+		if action == "remove" {
+			if err := RemoveBackup(c.Session, backup); err != nil {
+				c.Reply("⚠️ Error: " + err.Error())
+			} else {
+				c.Reply("✅ Backup Removed: " + backup)
+			}
+			return
+		}
+
+		backupCli := loadedClient(backup)
+		if backupCli == nil {
+			c.Reply("⚠️ Error: " + backup + " is not a paired session")
+			return
+		}
+		if err := AddBackup(c.Session, backup); err != nil {
+			c.Reply("⚠️ Error: " + err.Error())
+			return
+		}
+		// backup کا مالک اپنی Note to self میں درخواست دیکھتا ہے؛ قبول کیے بغیر failover نہیں
+		notifySelf(backupCli, fmt.Sprintf("🔁 *Backup Request*\n`%s` wants this session to send its OTPs when it is down.\n\nAccept: %sbackup accept %s\nReject: %sbackup reject %s",
+			c.Session, GetPrefix(backup), c.Session, GetPrefix(backup), c.Session))
+		c.Reply("⏳ Backup Requested: " + backup + "\nIt is used only after its owner sends " + GetPrefix(backup) + "backup accept " + c.Session + " on that session.\n\n" +
+			backupAdminReport(backupCli, GetUserSettings(c.Session).Channels))
+
+	case "accept", "reject":
+		if len(c.Args) < 2 {
+			c.Reply("❌ Usage: " + c.Prefix + "backup " + action + " <Owner_Number>")
+			return
+		}
+		// رضامندی صرف اس سیشن کا مالک دے سکتا ہے جو بطور backup بھیجے گا
+		if c.Role < RoleOwner {
+			c.Reply("⚠️ Error: Only this session's owner can " + action + " backup requests")
+			return
+		}
+		owner := getCleanID(normalizeNumber(c.Args[1]))
+		if action == "reject" {
+			if err := RemoveBackup(owner, c.Session); err != nil {
+				c.Reply("⚠️ Error: " + err.Error())
+			} else {
+				c.Reply("✅ No longer a backup for " + owner)
+			}
+			return
+		}
+		if err := AcceptBackup(owner, c.Session); err != nil {
+			c.Reply("⚠️ Error: " + err.Error())
 			return
 		}
-		channelID := args[1]
-		err := AddChannel(userJID, channelID)
+		c.Reply("✅ Backup Accepted: this session now sends for " + owner + " when it is down.\n\n" +
+			backupAdminReport(c.Cli, GetUserSettings(owner).Channels))
+
+	default:
+		c.Usage()
+	}
+}
+
+// backupAdminReport backup سیشن owner کے کن گروپس/چینلز میں admin نہیں (ابھی بتائیں، failover پر نہیں)
+func backupAdminReport(cli *whatsmeow.Client, channels []string) string {
+	if len(channels) == 0 {
+		return "ℹ️ No channels to check yet."
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	missing, unknown := []string{}, []string{}
+	for _, ch := range channels {
+		ok, err := isTargetAdmin(ctx, cli, ch)
+		switch {
+		case err != nil:
+			unknown = append(unknown, ch)
+		case !ok:
+			missing = append(missing, ch)
+		}
+	}
+	if len(missing) == 0 && len(unknown) == 0 {
+		return fmt.Sprintf("✅ Admin in all %d channels.", len(channels))
+	}
+	msg := ""
+	if len(missing) > 0 {
+		msg += "⚠️ *Not admin in:*\n• " + strings.Join(missing, "\n• ") + "\n"
+	}
+	if len(unknown) > 0 {
+		msg += "❔ *Could not check:*\n• " + strings.Join(unknown, "\n• ") + "\n"
+	}
+	return msg + "Make it admin there, or failover sends to those channels will fail."
+}
+
+// isTargetAdmin کیا cli اس گروپ/چینل میں پوسٹ کر سکتا ہے (DM ہمیشہ ہاں)
+func isTargetAdmin(ctx context.Context, cli *whatsmeow.Client, target string) (bool, error) {
+	jid, err := types.ParseJID(target)
+	if err != nil {
+		return false, err
+	}
+	switch jid.Server {
+	case types.GroupServer:
+		info, err := cli.GetGroupInfo(ctx, jid)
+		if err != nil {
+			return false, err
+		}
+		for _, p := range info.Participants {
+			if p.JID.User == cli.Store.ID.User || p.PhoneNumber.User == cli.Store.ID.User || (!cli.Store.LID.IsEmpty() && p.LID.User == cli.Store.LID.User) {
+				return p.IsAdmin || p.IsSuperAdmin, nil
+			}
+		}
+		return false, nil
+	case types.NewsletterServer:
+		meta, err := cli.GetNewsletterInfo(ctx, jid)
 		if err != nil {
-			reply(cli, evt, "⚠️ Error: "+err.Error())
+			return false, err
+		}
+		if meta.ViewerMeta == nil {
+			return false, nil
+		}
+		return meta.ViewerMeta.Role == types.NewsletterRoleAdmin || meta.ViewerMeta.Role == types.NewsletterRoleOwner, nil
+	}
+	return true, nil
+}
+
+// .watch <number>           → اسی سیشن کے لیے، DM بھیجنے والے کو
+// .watch <number> <session> → کسی paired سیشن کو نمبر assign (DM اس سیشن کے اپنے نمبر پر)
+// watch ہی canViewNumber کی اجازت ہے (مکمل نمبر اور کوڈ)، اس لیے صرف ADMIN_NUMBERS دے سکتے ہیں
+func cmdWatch(c *CommandContext) {
+	if !isAdmin(c.User) {
+		c.Reply("⚠️ Error: Only bot admins can assign watched numbers")
+		return
+	}
+	number := normalizeNumber(c.Args[0])
+	if !isDigits(number) {
+		c.Reply("⚠️ Error: Invalid number")
+		return
+	}
+	// DM اسی چیٹ پر جائے گا جس سے watch کیا گیا (Note to self کے لیے بوٹ خود)
+	w := Watch{Watcher: c.Session, Number: number, DMJID: c.Sender, Session: c.Session}
+	if c.Evt.Info.IsFromMe {
+		w.DMJID = c.Cli.Store.ID.ToNonAD().String()
+	}
+	if len(c.Args) > 1 {
+		w.Watcher = getCleanID(normalizeNumber(c.Args[1]))
+		ClientMutex.Lock()
+		_, paired := ActiveClients[w.Watcher]
+		ClientMutex.Unlock()
+		if !paired {
+			c.Reply("⚠️ Error: " + w.Watcher + " is not a paired session")
+			return
+		}
+		w.DMJID, w.Session = w.Watcher+"@"+types.DefaultUserServer, w.Watcher
+	}
+	if err := AddWatch(w); err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+	} else if w.Watcher != c.Session {
+		c.Reply("✅ Watching: " + number + "\nOTPs for this number will be sent privately to " + w.Watcher)
+	} else {
+		c.Reply("✅ Watching: " + number + "\nOTPs for this number will be sent to you privately.")
+	}
+}
+
+// .unwatch <number> | .unwatch all
+func cmdUnwatch(c *CommandContext) {
+	number := normalizeNumber(c.Args[0])
+	if strings.ToLower(c.Args[0]) == "all" {
+		number = ""
+	}
+	n, err := RemoveWatch(c.Session, number)
+	if err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+	} else {
+		c.Reply(fmt.Sprintf("✅ Removed %d watch(es)", n))
+	}
+}
+
+func cmdWatches(c *CommandContext) {
+	watches := GetWatches(c.Session)
+	msg := "👁️ *Watched Numbers:*\n"
+	if len(watches) == 0 {
+		msg += "No numbers watched."
+	}
+	for _, w := range watches {
+		msg += fmt.Sprintf("- `%s`\n", w.Number)
+	}
+	c.Reply(msg)
+}
+
+// .otp <number> | .otp <last digits>
+func cmdOTP(c *CommandContext) {
+	number := normalizeNumber(c.Args[0])
+	if !isDigits(number) || len(number) < 4 {
+		c.Reply("⚠️ Error: Give at least 4 digits")
+		return
+	}
+	// سیشن کے watches؛ ADMIN_NUMBERS والے سب دیکھ سکتے ہیں
+	viewer := c.Session
+	if isAdmin(c.User) {
+		viewer = c.User
+	}
+	records := lookupOTPs(viewer, number, 5)
+	if len(records) == 0 {
+		c.Reply("📭 No OTPs found (or you are not watching this number).")
+		return
+	}
+	msg := "🔎 *Recent OTPs:*\n"
+	for _, rec := range records {
+		msg += fmt.Sprintf("\n📱 *%s* | %s\n🔑 *%s* | %s\n🕒 %s | API %d\n",
+			rec.Phone, rec.Service, rec.Code, rec.Country, formatAge(rec.CreatedAt), rec.Source)
+	}
+	c.Reply(msg)
+}
+
+// HTTP API ٹوکن صرف پرائیویٹ چیٹ میں
+func cmdToken(c *CommandContext) {
+	if c.Evt.Info.IsGroup || c.Evt.Info.Chat.Server == types.NewsletterServer {
+		c.Reply("⚠️ Use " + c.Prefix + "token in a private chat.")
+		return
+	}
+	token := newAPIToken()
+	if err := SetAPIToken(c.User, token); err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+		return
+	}
+	c.Reply("🔐 *API Token:*\n`" + token + "`\n\nAny old token is now revoked.")
+}
+
+// .sources [channel]
+// .sources subscribe|unsubscribe <name> [channel]
+func cmdSources(c *CommandContext) {
+	if len(c.Args) < 2 {
+		channel := ""
+		if len(c.Args) == 1 {
+			channel = c.Args[0]
+		}
+		active := map[int]bool{}
+		for _, idx := range GetSourceSubs(c.Session).Effective(channel) {
+			active[idx] = true
+		}
+		msg := "📡 *OTP Sources:*\n"
+		if channel != "" {
+			msg = "📡 *OTP Sources for* `" + channel + "`:\n"
+		}
+		for i := range Config.OTPApiURLs {
+			mark := "❌"
+			if active[i+1] {
+				mark = "✅"
+			}
+			msg += fmt.Sprintf("%s %d. %s\n", mark, i+1, sourceName(i+1))
+		}
+		msg += "\nUsage: " + c.Prefix + "sources subscribe|unsubscribe <name> [Channel_ID]"
+		c.Reply(msg)
+		return
+	}
+
+	action := strings.ToLower(c.Args[0])
+	src := sourceIndex(c.Args[1])
+	if src == 0 {
+		c.Reply("⚠️ Error: Unknown source " + c.Args[1])
+		return
+	}
+	channel := ""
+	if len(c.Args) > 2 {
+		channel = c.Args[2]
+	}
+
+	if action != "subscribe" && action != "sub" && action != "unsubscribe" && action != "unsub" {
+		c.Usage()
+		return
+	}
+
+	// پڑھنا اور لکھنا ایک ہی ٹرانزیکشن میں (ایک ساتھ آنے والی کمانڈز محفوظ)
+	list, err := UpdateSourceSubs(c.Session, channel, func(subs SourceSubs) ([]int, error) {
+		// موجودہ effective لسٹ سے شروع کریں تاکہ "سب" سے unsubscribe بھی کام کرے
+		set := map[int]bool{}
+		for _, idx := range subs.Effective(channel) {
+			set[idx] = true
+		}
+		if action == "subscribe" || action == "sub" {
+			if len(subs[channel]) == 0 {
+				set = map[int]bool{} // اس لیول پر پہلی subscription: صرف یہی source
+			}
+			set[src] = true
 		} else {
-			reply(cli, evt, "✅ Channel Activated!\nMessages will now flow to: "+channelID)
+			delete(set, src)
+		}
+		if len(set) == 0 {
+			return nil, fmt.Errorf("At least one source must stay subscribed")
+		}
+
+		list := []int{}
+		for i := 1; i <= len(Config.OTPApiURLs); i++ {
+			if set[i] {
+				list = append(list, i)
+			}
+		}
+		return list, nil
+	})
+	if err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+		return
+	}
+	names := []string{}
+	for _, idx := range list {
+		names = append(names, sourceName(idx))
+	}
+	c.Reply("✅ Sources Updated!\nReceiving from: " + strings.Join(names, ", "))
+}
+
+// .webhook add <url> | .webhook remove <id> | .webhook list
+func cmdWebhook(c *CommandContext) {
+	switch strings.ToLower(c.Args[0]) {
+	case "list":
+		hooks := GetWebhooks(c.Session)
+		msg := "🪝 *Webhooks:*\n"
+		if len(hooks) == 0 {
+			msg += "No webhooks set."
+		}
+		for _, h := range hooks {
+			msg += fmt.Sprintf("#%d `%s`\n", h.ID, h.URL)
 		}
+		c.Reply(msg)
 
-	case ".deactive":
-		if len(args) < 2 {
-			reply(cli, evt, "❌ Usage: .deactive <Channel_ID>")
+	case "add":
+		if len(c.Args) < 2 || !(strings.HasPrefix(c.Args[1], "https://") || strings.HasPrefix(c.Args[1], "http://")) {
+			c.Reply("❌ Usage: " + c.Prefix + "webhook add <http(s)://URL>")
+			return
+		}
+		// Secret صرف پرائیویٹ چیٹ میں دکھائیں
+		if c.Evt.Info.IsGroup || c.Evt.Info.Chat.Server == types.NewsletterServer {
+			c.Reply("⚠️ Use " + c.Prefix + "webhook add in a private chat.")
 			return
 		}
-		channelID := args[1]
-		err := RemoveChannel(userJID, channelID)
+		secret := newAPIToken()
+		id, err := AddWebhook(c.Session, c.Args[1], secret)
 		if err != nil {
-			reply(cli, evt, "⚠️ Error: "+err.Error())
+			c.Reply("⚠️ Error: " + err.Error())
+			return
+		}
+		c.Reply(fmt.Sprintf("✅ Webhook #%d Added!\n🔐 *Secret:* `%s`\n\nVerify header X-Kami-Signature = sha256=HMAC-SHA256(secret, X-Kami-Timestamp + \".\" + body)\nand reject timestamps older than %d minutes.", id, secret, int(WebhookTolerance.Minutes())))
+
+	case "remove":
+		if len(c.Args) < 2 {
+			c.Reply("❌ Usage: " + c.Prefix + "webhook remove <ID>")
+			return
+		}
+		id, _ := strconv.ParseInt(strings.TrimPrefix(c.Args[1], "#"), 10, 64)
+		if err := RemoveWebhook(c.Session, id); err != nil {
+			c.Reply("⚠️ Error: " + err.Error())
 		} else {
-			reply(cli, evt, "✅ Channel Deactivated!")
+			c.Reply("✅ Webhook Removed!")
 		}
 
-	case ".change":
-		if len(args) < 2 {
-			reply(cli, evt, "❌ Usage: .change <New_Link>")
+	default:
+		c.Usage()
+	}
+}
+
+// .sink add telegram <bot_token> <chat_id>
+// .sink add discord <webhook_url>
+// .sink template <id> <text with {placeholders}>
+// .sink remove <id> | .sink list
+func cmdSink(c *CommandContext) {
+	switch strings.ToLower(c.Args[0]) {
+	case "list":
+		sinks := GetSinks(c.Session)
+		msg := "📣 *Sinks:*\n"
+		if len(sinks) == 0 {
+			msg += "No sinks set."
+		}
+		for _, s := range sinks {
+			target := s.Target
+			if s.Kind == "discord" {
+				target = maskPhoneNumber(target)
+			}
+			tpl := "default"
+			if s.Template != "" {
+				tpl = "custom"
+			}
+			msg += fmt.Sprintf("#%d %s `%s` (template: %s)\n", s.ID, s.Kind, target, tpl)
+		}
+		msg += "\nPlaceholders: {flag} {country} {service} {SERVICE} {number} {full_number} {code} {time} {source} {message} {link}"
+		c.Reply(msg)
+
+	case "add":
+		if c.Evt.Info.IsGroup || c.Evt.Info.Chat.Server == types.NewsletterServer {
+			c.Reply("⚠️ Use " + c.Prefix + "sink add in a private chat.")
 			return
 		}
-		newLink := args[1]
-		SetCustomLink(userJID, newLink)
-		reply(cli, evt, "✅ Footer Link Updated!\nNew Link: "+newLink)
+		sink := Sink{Owner: c.Session}
+		switch {
+		case len(c.Args) >= 4 && strings.ToLower(c.Args[1]) == "telegram":
+			sink.Kind, sink.Token, sink.Target = "telegram", c.Args[2], c.Args[3]
+		case len(c.Args) >= 3 && strings.ToLower(c.Args[1]) == "discord" && strings.HasPrefix(c.Args[2], "https://"):
+			sink.Kind, sink.Target = "discord", c.Args[2]
+		default:
+			c.Usage()
+			return
+		}
+		id, err := AddSink(sink)
+		if err != nil {
+			c.Reply("⚠️ Error: " + err.Error())
+		} else {
+			c.Reply(fmt.Sprintf("✅ %s Sink #%d Added!", sink.Kind, id))
+		}
+
+	case "template":
+		if len(c.Args) < 2 {
+			c.Usage()
+			return
+		}
+		id, _ := strconv.ParseInt(strings.TrimPrefix(c.Args[1], "#"), 10, 64)
+		// باقی پورا متن (نئی لائنوں سمیت) template ہے، خالی = default
+		tpl := ""
+		rest := c.Text[len(c.Args[0]):]
+		if idx := strings.Index(rest, c.Args[1]); idx >= 0 {
+			tpl = strings.TrimSpace(rest[idx+len(c.Args[1]):])
+		}
+		if err := SetSinkTemplate(c.Session, id, tpl); err != nil {
+			c.Reply("⚠️ Error: " + err.Error())
+		} else {
+			c.Reply("✅ Sink Template Updated!")
+		}
+
+	case "remove":
+		if len(c.Args) < 2 {
+			c.Usage()
+			return
+		}
+		id, _ := strconv.ParseInt(strings.TrimPrefix(c.Args[1], "#"), 10, 64)
+		if err := RemoveSink(c.Session, id); err != nil {
+			c.Reply("⚠️ Error: " + err.Error())
+		} else {
+			c.Reply("✅ Sink Removed!")
+		}
+
+	default:
+		c.Usage()
+	}
+}
+
+// .schedule                                   → list
+// .schedule <Channel|here> off
+// .schedule <Channel|here> <days> <HH-HH> <TZ> [drop|hold]
+// e.g. .schedule here mon-fri 09-18 Asia/Karachi hold
+func cmdSchedule(c *CommandContext) {
+	if len(c.Args) == 0 {
+		scheds := GetSchedules(c.Session)
+		msg := "⏰ *Channel Schedules:*\n"
+		if len(scheds) == 0 {
+			msg += "No schedules set (OTPs flow 24/7)."
+		}
+		for _, sc := range scheds {
+			state := "🔴 Closed"
+			if sc.IsOpen(time.Now()) {
+				state = "🟢 Open"
+			}
+			msg += fmt.Sprintf("- `%s`\n  %s %s", sc.Channel, sc.String(), state)
+			if held := CountHeldOTPs(c.Session, sc.Channel); held > 0 {
+				msg += fmt.Sprintf(" | %d held", held)
+			}
+			msg += "\n"
+		}
+		c.Reply(msg)
+		return
+	}
 
-	case ".list":
-		settings := GetUserSettings(userJID)
-		msg := "📋 *Active Channels:*\n"
-		if len(settings.Channels) == 0 {
-			msg += "No active channels."
+	channel := c.Args[0]
+	if strings.ToLower(channel) == "here" {
+		channel = c.Evt.Info.Chat.ToNonAD().String()
+	}
+	if len(c.Args) == 2 && strings.ToLower(c.Args[1]) == "off" {
+		if n, err := RemoveSchedule(c.Session, channel); err != nil {
+			c.Reply("⚠️ Error: " + err.Error())
 		} else {
-			for _, ch := range settings.Channels {
-				msg += fmt.Sprintf("- `%s`\n", ch)
+			c.Reply("✅ Schedule Removed! OTPs flow 24/7 to " + channel + heldDiscarded(n))
+		}
+		return
+	}
+	if len(c.Args) < 4 {
+		c.Usage()
+		return
+	}
+
+	days, err := parseDays(c.Args[1])
+	if err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+		return
+	}
+	start, end, err := parseHours(c.Args[2])
+	if err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+		return
+	}
+	if _, err := time.LoadLocation(c.Args[3]); err != nil {
+		c.Reply("⚠️ Error: Unknown timezone " + c.Args[3])
+		return
+	}
+	mode := "drop"
+	if len(c.Args) > 4 {
+		mode = strings.ToLower(c.Args[4])
+		if mode != "drop" && mode != "hold" {
+			c.Usage()
+			return
+		}
+	}
+
+	sched := Schedule{Owner: c.Session, Channel: channel, Days: days, StartHour: start, EndHour: end, TZ: c.Args[3], Mode: mode}
+	if n, err := SetSchedule(sched); err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+	} else {
+		c.Reply("✅ Schedule Set!\n`" + channel + "`\n" + sched.String() + heldDiscarded(n))
+	}
+}
+
+func heldDiscarded(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n🗑️ %d held OTP(s) discarded", n)
+}
+
+// .search <text> [country:x] [service:y] [source:z] [phone:123] [page:n]
+func cmdSearch(c *CommandContext) {
+	f, page := parseSearchArgs(c.Args)
+	restrictForUser(&f, c.Session, isAdmin(c.User))
+
+	const perPage = 10
+	f.Limit, f.Offset = perPage, (page-1)*perPage
+	records, total := SearchOTPRecords(f)
+	if total == 0 {
+		c.Reply("📭 No OTPs matched.")
+		return
+	}
+
+	// زیادہ نتائج ہوں تو پورا صفحہ CSV ڈاکیومنٹ کے طور پر
+	if total > perPage && page == 1 {
+		f.Limit, f.Offset = searchDocumentLimit, 0
+		all, _ := SearchOTPRecords(f)
+		caption := fmt.Sprintf("🔎 %d matches (showing %d)", total, len(all))
+		if err := sendPrivateDocument(c, "otp_search.csv", "text/csv", writeOTPCSV(all), caption); err != nil {
+			c.Reply("⚠️ Error: " + err.Error())
+		}
+		return
+	}
+
+	msg := fmt.Sprintf("🔎 *Search Results* (page %d, %d total):\n", page, total)
+	for _, rec := range records {
+		msg += fmt.Sprintf("\n📱 *%s* | %s\n🔑 *%s* | %s\n🕒 %s | %s\n",
+			rec.Phone, rec.Service, rec.Code, rec.Country, formatAge(rec.CreatedAt), sourceName(rec.Source))
+	}
+	c.Reply(msg)
+}
+
+// .export [otp|deliveries] [csv|json] [from:YYYY-MM-DD] [to:YYYY-MM-DD] [country:x] [service:y] [source:z]
+func cmdExport(c *CommandContext) {
+	kind, format := "otp", "csv"
+	rest := []string{}
+	for _, a := range c.Args {
+		switch strings.ToLower(a) {
+		case "otp", "otps", "deliveries", "delivery":
+			kind = strings.ToLower(a)
+		case "csv", "json":
+			format = strings.ToLower(a)
+		default:
+			rest = append(rest, a)
+		}
+	}
+	f, _ := parseSearchArgs(rest)
+	if f.Text != "" {
+		c.Usage()
+		return
+	}
+
+	name, mime, data, count, err := buildExport(kind, format, f, c.Session, isAdmin(c.User))
+	if err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+		return
+	}
+	if count == 0 {
+		c.Reply("📭 Nothing to export for these filters.")
+		return
+	}
+	if err := sendPrivateDocument(c, name, mime, data, fmt.Sprintf("📦 %d rows", count)); err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+	}
+}
+
+func cmdList(c *CommandContext) {
+	settings := GetUserSettings(c.Session)
+	msg := "📋 *Active Channels:*\n"
+	if len(settings.Channels) == 0 {
+		msg += "No active channels."
+	} else {
+		for _, ch := range settings.Channels {
+			msg += fmt.Sprintf("- `%s`\n", ch)
+		}
+	}
+	msg += "\n🔗 *Current Link:*\n" + settings.CustomLink
+	c.Reply(msg)
+}
+
+// resolveTarget .active/.deactive کے arguments کو JID میں بدلتا ہے
+// کوئی argument نہ ہو تو وہی چیٹ جہاں کمانڈ بھیجی گئی
+func resolveTarget(cli *whatsmeow.Client, evt *events.Message, args []string) (string, error) {
+	if len(args) == 0 {
+		return evt.Info.Chat.ToNonAD().String(), nil
+	}
+
+	input := args[0]
+	join := len(args) > 1 && strings.ToLower(args[1]) == "join"
+	ctx := context.Background()
+
+	kind, code := parseInviteLink(input)
+	switch kind {
+	case "group":
+		if code == "" {
+			return "", fmt.Errorf("Invalid group link")
+		}
+		if join {
+			jid, err := cli.JoinGroupWithLink(ctx, code)
+			if err != nil {
+				return "", fmt.Errorf("Could not join group: %v", err)
 			}
+			return jid.String(), nil
 		}
-		msg += "\n🔗 *Current Link:*\n" + settings.CustomLink
-		reply(cli, evt, msg)
+		info, err := cli.GetGroupInfoFromLink(ctx, code)
+		if err != nil {
+			return "", fmt.Errorf("Could not resolve group link: %v", err)
+		}
+		return info.JID.String(), nil
+
+	case "channel":
+		if code == "" {
+			return "", fmt.Errorf("Invalid channel link")
+		}
+		meta, err := cli.GetNewsletterInfoWithInvite(ctx, code)
+		if err != nil {
+			return "", fmt.Errorf("Could not resolve channel link: %v", err)
+		}
+		if join {
+			if err := cli.FollowNewsletter(ctx, meta.ID); err != nil {
+				return "", fmt.Errorf("Could not follow channel: %v", err)
+			}
+		}
+		return meta.ID.String(), nil
+	}
+
+	jid, err := types.ParseJID(input)
+	if err != nil || !strings.Contains(input, "@") {
+		return "", fmt.Errorf("Invalid Channel ID or Link: %s", input)
+	}
+	return jid.ToNonAD().String(), nil
+}
+
+const searchDocumentLimit = 1000
+
+// sendPrivateDocument OTP ہسٹری کی فائلیں کبھی گروپ/چینل میں نہیں، صرف بھیجنے والے کے DM میں
+// (Note to self کے لیے بوٹ خود)؛ گروپ سے کمانڈ ہو تو وہاں صرف اطلاع
+func sendPrivateDocument(c *CommandContext, fileName, mimeType string, data []byte, caption string) error {
+	dm := c.Evt.Info.Sender.ToNonAD()
+	if c.Evt.Info.IsFromMe {
+		dm = c.Cli.Store.ID.ToNonAD()
 	}
+	if err := sendDocument(c.Cli, dm, fileName, mimeType, data, caption); err != nil {
+		return err
+	}
+	if c.Evt.Info.Chat.ToNonAD() != dm {
+		c.Reply("📩 Sent to you privately.")
+	}
+	return nil
+}
+
+// sendDocument فائل اپ لوڈ کر کے ڈاکیومنٹ میسج بھیجتا ہے
+func sendDocument(cli *whatsmeow.Client, chat types.JID, fileName, mimeType string, data []byte, caption string) error {
+	up, err := cli.Upload(context.Background(), data, whatsmeow.MediaDocument)
+	if err != nil {
+		return err
+	}
+	_, err = cli.SendMessage(context.Background(), chat, &waProto.Message{
+		DocumentMessage: &waProto.DocumentMessage{
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+			Mimetype:      proto.String(mimeType),
+			FileName:      proto.String(fileName),
+			Title:         proto.String(fileName),
+			Caption:       proto.String(caption),
+		},
+	})
+	return err
+}
+
+// notifySelf سیشن کی اپنی چیٹ (Note to self) میں میسج
+func notifySelf(cli *whatsmeow.Client, text string) {
+	if cli.Store.ID == nil {
+		return
+	}
+	cli.SendMessage(context.Background(), cli.Store.ID.ToNonAD(), &waProto.Message{
+		Conversation: proto.String(text),
+	})
 }
 
 func reply(cli *whatsmeow.Client, evt *events.Message, text string) {
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ---------------------------------------------------------
+// 🧰 COMMAND REGISTRY
+// ---------------------------------------------------------
+
+// Role کمانڈ چلانے کے لیے کم از کم درجہ
+type Role int
+
+// RolePublic کوئی کمانڈ نہیں چلا سکتا (.help بھی نہیں)، تاکہ گروپ کے لوگ بوٹ کو probe نہ کریں
+const (
+	RolePublic Role = iota
+	RoleViewer
+	RoleAdmin
+	RoleOwner
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleAdmin:
+		return "admin"
+	case RoleOwner:
+		return "owner"
+	}
+	return "public"
+}
+
+// Command رجسٹری کی ایک entry؛ .help اسی سے بنتا ہے
+type Command struct {
+	Name    string   // بغیر prefix کے، e.g. "active"
+	Aliases []string // متبادل نام
+	Usage   []string // argument spec، ہر لائن ایک شکل (خالی = کوئی argument نہیں)
+	MinArgs int      // اس سے کم ہوں تو Usage دکھا دیں
+	Desc    string
+	Role    Role
+	Quoted  bool // ایک ویلیو والی کمانڈ: بغیر args کے reply ہو تو quoted متن (ایک لفظ) ہی arg
+	Run     func(c *CommandContext)
+}
+
+var (
+	commandList  []*Command
+	commandIndex = map[string]*Command{}
+)
+
+func registerCommands(cmds ...*Command) {
+	for _, cmd := range cmds {
+		for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+			if _, dup := commandIndex[name]; dup {
+				panic("❌ Duplicate command name: " + name)
+			}
+			commandIndex[name] = cmd
+		}
+		commandList = append(commandList, cmd)
+	}
+}
+
+// CommandContext ایک کمانڈ کی ایک بار کی execution
+type CommandContext struct {
+	Cli     *whatsmeow.Client
+	Evt     *events.Message
+	Cmd     *Command
+	Prefix  string
+	Args    []string // کمانڈ کے نام کے بعد
+	Text    string   // کمانڈ کے نام کے بعد کا پورا متن (نئی لائنوں سمیت)
+	Sender  string   // جیسا آیا (LID بھی ہو سکتا ہے)
+	User    string   // resolved نمبر (بھیجنے والا شخص)
+	Session string   // جس سیشن (بوٹ نمبر) پر کمانڈ آئی، settings اسی کی
+	Role    Role     // اس سیشن پر User کا درجہ
+}
+
+func (c *CommandContext) Reply(text string) {
+	reply(c.Cli, c.Evt, text)
+}
+
+// Usage رجسٹری کے argument spec سے "❌ Usage:" میسج
+func (c *CommandContext) Usage() {
+	c.Reply("❌ Usage:" + usageLines(c.Cmd, c.Prefix))
+}
+
+func usageLines(cmd *Command, prefix string) string {
+	if len(cmd.Usage) == 0 {
+		return " " + prefix + cmd.Name
+	}
+	if len(cmd.Usage) == 1 {
+		return " " + prefix + cmd.Name + " " + cmd.Usage[0]
+	}
+	out := ""
+	for _, u := range cmd.Usage {
+		out += "\n" + prefix + cmd.Name + " " + u
+	}
+	return out
+}
+
+// messageText میسج کا متن (text، extended text یا media caption) اور quoted میسج کا متن
+func messageText(msg *waProto.Message) (text, quoted string) {
+	if msg == nil {
+		return "", ""
+	}
+	var ctx *waProto.ContextInfo
+	switch {
+	case msg.GetConversation() != "":
+		text = msg.GetConversation()
+	case msg.ExtendedTextMessage != nil:
+		text, ctx = msg.ExtendedTextMessage.GetText(), msg.ExtendedTextMessage.GetContextInfo()
+	case msg.ImageMessage != nil:
+		text, ctx = msg.ImageMessage.GetCaption(), msg.ImageMessage.GetContextInfo()
+	case msg.VideoMessage != nil:
+		text, ctx = msg.VideoMessage.GetCaption(), msg.VideoMessage.GetContextInfo()
+	case msg.DocumentMessage != nil:
+		text, ctx = msg.DocumentMessage.GetCaption(), msg.DocumentMessage.GetContextInfo()
+	case msg.DocumentWithCaptionMessage != nil:
+		return messageText(msg.DocumentWithCaptionMessage.GetMessage())
+	}
+	if q := ctx.GetQuotedMessage(); q != nil {
+		quoted, _ = messageText(q)
+	}
+	return text, quoted
+}
+
+// parseCommand "<prefix><name> <rest>" → name (lowercase) اور باقی متن
+func parseCommand(text, prefix string) (name, rest string, ok bool) {
+	text = strings.TrimSpace(text)
+	if prefix == "" || !strings.HasPrefix(text, prefix) {
+		return "", "", false
+	}
+	text = text[len(prefix):]
+	end := strings.IndexFunc(text, unicode.IsSpace)
+	if end < 0 {
+		end = len(text)
+	}
+	name = strings.ToLower(text[:end])
+	if name == "" {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(text[end:]), true
+}
+
+// validPrefix 1-3 علامات، حروف/ہندسے/اسپیس نہیں (ورنہ عام میسج کمانڈ بن جائیں)
+func validPrefix(p string) bool {
+	if p == "" || len([]rune(p)) > 3 {
+		return false
+	}
+	for _, r := range p {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ---------------------------------------------------------
+// 📖 HELP
+// ---------------------------------------------------------
+
+func cmdHelp(c *CommandContext) {
+	if len(c.Args) > 0 {
+		name := strings.TrimPrefix(strings.ToLower(c.Args[0]), c.Prefix)
+		cmd, ok := commandIndex[name]
+		if !ok || c.Role < cmd.Role {
+			c.Reply("⚠️ Error: Unknown command " + c.Args[0])
+			return
+		}
+		msg := fmt.Sprintf("📖 *%s%s*\n%s\n\n*Usage:*%s", c.Prefix, cmd.Name, cmd.Desc, usageLines(cmd, c.Prefix))
+		if len(cmd.Aliases) > 0 {
+			msg += "\n\n*Aliases:* " + c.Prefix + strings.Join(cmd.Aliases, ", "+c.Prefix)
+		}
+		msg += "\n*Role:* " + cmd.Role.String()
+		if cmd.Quoted {
+			msg += "\n↩️ Reply to a message with no arguments to use its text as the value."
+		}
+		c.Reply(msg)
+		return
+	}
+
+	// صرف وہ کمانڈز جو یہ role چلا سکتا ہے
+	msg := fmt.Sprintf("📖 *Commands* (prefix `%s`, role: %s)\n", c.Prefix, c.Role)
+	for _, cmd := range commandList {
+		if c.Role >= cmd.Role {
+			msg += fmt.Sprintf("\n*%s%s* — %s", c.Prefix, cmd.Name, cmd.Desc)
+		}
+	}
+	msg += fmt.Sprintf("\n\nDetails: %shelp <command>", c.Prefix)
+	c.Reply(msg)
+}
+
+func cmdPrefix(c *CommandContext) {
+	if len(c.Args) == 0 {
+		c.Reply(fmt.Sprintf("🔣 *Prefix:* `%s`\nChange: %sprefix <symbol> / %sprefix reset", c.Prefix, c.Prefix, c.Prefix))
+		return
+	}
+	prefix := c.Args[0]
+	if strings.ToLower(prefix) == "reset" {
+		prefix = ""
+	} else if !validPrefix(prefix) {
+		c.Reply("⚠️ Error: Prefix must be 1-3 symbols (no letters, digits or spaces)")
+		return
+	}
+	if err := SetPrefix(c.Session, prefix); err != nil {
+		c.Reply("⚠️ Error: " + err.Error())
+		return
+	}
+	c.Reply(fmt.Sprintf("✅ Prefix Updated!\nCommands now start with `%s` (e.g. %shelp)", GetPrefix(c.Session), GetPrefix(c.Session)))
+}
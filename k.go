@@ -0,0 +1,702 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// ---------------------------------------------------------
+// 🔒 SESSION KEYS ENCRYPTION AT REST
+// ---------------------------------------------------------
+
+// Sealed value: "KSEAL" | keyID(1) | nonce(12) | AES-256-GCM(plaintext)
+// keyID سے پتہ چلتا ہے کہ کس key سے seal ہوا (rotation کے لیے)
+const sealMagic = "KSEAL"
+
+// sealedColumn whatsmeow کے وہ کالمز جن میں private keys / session state ہے
+type sealedColumn struct {
+	table   string
+	keys    []string // primary key (reseal کے UPDATE کے لیے)
+	columns []string
+}
+
+var sealedColumns = []sealedColumn{
+	{"whatsmeow_device", []string{"jid"}, []string{"noise_key", "identity_key", "signed_pre_key", "adv_key"}},
+	{"whatsmeow_pre_keys", []string{"jid", "key_id"}, []string{"key"}},
+	{"whatsmeow_sessions", []string{"our_jid", "their_id"}, []string{"session"}},
+	{"whatsmeow_sender_keys", []string{"our_jid", "chat_id", "sender_id"}, []string{"sender_key"}},
+	{"whatsmeow_app_state_sync_keys", []string{"jid", "key_id"}, []string{"key_data"}},
+}
+
+var (
+	sealKeys     = map[byte][]byte{}
+	sealActiveID byte
+	sealKeysOnce sync.Once
+	sealKeysErr  error
+)
+
+func ensureSealKeys() error {
+	sealKeysOnce.Do(func() { sealKeysErr = loadSealKeys() })
+	return sealKeysErr
+}
+
+// loadSealKeys پہلی key active ہے، باقی صرف پرانا ڈیٹا کھولنے کے لیے
+func loadSealKeys() error {
+	entries := append([]string{}, SessionKeys...)
+	if SessionKeyFile != "" {
+		f, err := os.Open(SessionKeyFile)
+		if err != nil {
+			return fmt.Errorf("Key file: %v", err)
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				entries = append(entries, line)
+			}
+		}
+	}
+
+	for i, entry := range entries {
+		idStr, b64, found := strings.Cut(entry, ":")
+		id, err := strconv.Atoi(idStr)
+		if !found || err != nil || id < 1 || id > 255 {
+			return fmt.Errorf("Invalid key entry #%d (want id:base64, id 1-255)", i+1)
+		}
+		key, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("Key %d must be 32 bytes base64", id)
+		}
+		if _, dup := sealKeys[byte(id)]; dup {
+			return fmt.Errorf("Duplicate key id %d", id)
+		}
+		sealKeys[byte(id)] = key
+		if i == 0 {
+			sealActiveID = byte(id)
+		}
+	}
+	return nil
+}
+
+func sealEnabled() bool {
+	return sealActiveID != 0
+}
+
+func isSealed(b []byte) bool {
+	return len(b) > len(sealMagic)+1+12 && string(b[:len(sealMagic)]) == sealMagic
+}
+
+func sealedKeyID(b []byte) byte {
+	return b[len(sealMagic)]
+}
+
+func sealValue(plain []byte) ([]byte, error) {
+	gcm, err := sealCipher(sealActiveID)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	out := append([]byte(sealMagic), sealActiveID)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plain, []byte(sealMagic)), nil
+}
+
+func openValue(sealed []byte) ([]byte, error) {
+	id := sealedKeyID(sealed)
+	gcm, err := sealCipher(id)
+	if err != nil {
+		return nil, err
+	}
+	rest := sealed[len(sealMagic)+1:]
+	nonce, ct := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ct, []byte(sealMagic))
+	if err != nil {
+		return nil, fmt.Errorf("Could not open sealed value with key %d", id)
+	}
+	return plain, nil
+}
+
+func sealCipher(id byte) (cipher.AEAD, error) {
+	key, ok := sealKeys[id]
+	if !ok {
+		return nil, fmt.Errorf("Session encryption key %d not configured", id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ---------------------------------------------------------
+// 🧩 SEALING SQL DRIVER (whatsmeow کے لیے شفاف)
+// ---------------------------------------------------------
+
+// INSERT INTO <table> (<cols>) VALUES (<placeholders>) میں sealed کالمز کے arg ordinals
+var (
+	insertRe      = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+(\w+)\s*\(([^)]*)\)\s*VALUES\s*\(([^)]*)\)`)
+	sealPlanCache sync.Map // query -> []int
+)
+
+func sealPlan(query string) []int {
+	if cached, ok := sealPlanCache.Load(query); ok {
+		return cached.([]int)
+	}
+	plan := []int{}
+	if m := insertRe.FindStringSubmatch(query); m != nil {
+		var cols []string
+		for _, sc := range sealedColumns {
+			if strings.EqualFold(sc.table, m[1]) {
+				cols = sc.columns
+			}
+		}
+		names := strings.Split(m[2], ",")
+		values := strings.Split(m[3], ",")
+		positional := 0
+		for i, name := range names {
+			if i >= len(values) {
+				break
+			}
+			v := strings.TrimSpace(values[i])
+			ordinal := 0
+			// "$1" (postgres)، "?1" (sqlite پر dbutil) یا سادہ "?"
+			if v == "?" {
+				positional++
+				ordinal = positional
+			} else if strings.HasPrefix(v, "$") || strings.HasPrefix(v, "?") {
+				ordinal, _ = strconv.Atoi(v[1:])
+			}
+			for _, c := range cols {
+				if strings.EqualFold(strings.TrimSpace(name), c) && ordinal > 0 {
+					plan = append(plan, ordinal)
+				}
+			}
+		}
+	}
+	sealPlanCache.Store(query, plan)
+	return plan
+}
+
+// updateRe UPDATE <table> SET ... ؛ sealing صرف INSERT ... VALUES پر ہوتی ہے
+var updateRe = regexp.MustCompile(`(?is)^\s*UPDATE\s+(\w+)\s+SET\s+(.*?)(?:\s+WHERE\s|$)`)
+
+// unsealedWrite sealed کالم میں UPDATE سے لکھنا plaintext محفوظ کر دیتا، اس لیے write ہی ناکام
+func unsealedWrite(query string) error {
+	m := updateRe.FindStringSubmatch(query)
+	if m == nil {
+		return nil
+	}
+	for _, sc := range sealedColumns {
+		if !strings.EqualFold(sc.table, m[1]) {
+			continue
+		}
+		for _, col := range sc.columns {
+			if regexp.MustCompile(`(?i)\b` + col + `\s*=`).MatchString(m[2]) {
+				return fmt.Errorf("Unsealed write to %s.%s (only INSERT ... VALUES is sealed)", sc.table, col)
+			}
+		}
+	}
+	return nil
+}
+
+func sealArgs(query string, args []driver.NamedValue) ([]driver.NamedValue, error) {
+	if err := unsealedWrite(query); err != nil {
+		return nil, err
+	}
+	plan := sealPlan(query)
+	if len(plan) == 0 {
+		return args, nil
+	}
+	out := make([]driver.NamedValue, len(args))
+	copy(out, args)
+	for i, a := range out {
+		for _, ord := range plan {
+			if a.Ordinal != ord {
+				continue
+			}
+			switch v := a.Value.(type) {
+			case nil:
+			case []byte:
+				if isSealed(v) {
+					continue
+				}
+				sealed, err := sealValue(v)
+				if err != nil {
+					return nil, err
+				}
+				out[i].Value = sealed
+			default:
+				return nil, fmt.Errorf("Sealed column arg $%d is %T, not bytes", ord, a.Value)
+			}
+		}
+	}
+	return out, nil
+}
+
+type sealedDriver struct{ base driver.Driver }
+
+func (d *sealedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sealedConn{c}, nil
+}
+
+type sealedConn struct{ driver.Conn }
+
+func (c *sealedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sealedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var st driver.Stmt
+	var err error
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		st, err = pc.PrepareContext(ctx, query)
+	} else {
+		st, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sealedStmt{st, query}, nil
+}
+
+func (c *sealedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *sealedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	args, err := sealArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return e.ExecContext(ctx, query, args)
+}
+
+func (c *sealedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	args, err := sealArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &sealedRows{rows}, nil
+}
+
+func (c *sealedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sealedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *sealedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *sealedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type sealedStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *sealedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	args, err := sealArgs(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	return s.Stmt.Exec(namedValues(args))
+}
+
+func (s *sealedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	args, err := sealArgs(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	var rows driver.Rows
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sealedRows{rows}, nil
+}
+
+func (s *sealedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, a := range args {
+		vals[i] = a.Value
+	}
+	return vals
+}
+
+// sealedRows پڑھتے وقت ہر sealed ویلیو کو کھول دیتا ہے
+type sealedRows struct{ driver.Rows }
+
+func (r *sealedRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, v := range dest {
+		if b, ok := v.([]byte); ok && isSealed(b) {
+			plain, err := openValue(b)
+			if err != nil {
+				return err
+			}
+			dest[i] = plain
+		}
+	}
+	return nil
+}
+
+func init() {
+	sql.Register("sqlite3-sealed", &sealedDriver{&sqlite3.SQLiteDriver{}})
+	sql.Register("postgres-sealed", &sealedDriver{&pq.Driver{}})
+}
+
+// ---------------------------------------------------------
+// 🔁 MIGRATION / ROTATION
+// ---------------------------------------------------------
+
+// relaxSealedConstraints whatsmeow کے "length(x) = 32" CHECKs ہٹاتا ہے
+// کیونکہ sealed ویلیو لمبی ہوتی ہے۔ دوبارہ چلانا محفوظ ہے
+func relaxSealedConstraints(raw *sql.DB, dialect string) error {
+	if dialect == "postgres" {
+		for _, sc := range sealedColumns {
+			for _, col := range sc.columns {
+				if _, err := raw.Exec(fmt.Sprintf(`ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s_%s_check`, sc.table, sc.table, col)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// SQLite میں CHECK ہٹانے کے لیے ٹیبل دوبارہ بنانا پڑتا ہے (foreign keys بند کر کے)
+	ctx := context.Background()
+	conn, err := raw.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, sc := range sealedColumns {
+		var ddl string
+		err := conn.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = $1", sc.table).Scan(&ddl)
+		if err == sql.ErrNoRows {
+			continue // ٹیبل ابھی موجود نہیں
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", sc.table, err)
+		}
+		relaxed := ddl
+		for _, col := range sc.columns {
+			re := regexp.MustCompile(`(?i)CHECK\s*\(\s*length\s*\(\s*` + col + `\s*\)\s*=\s*\d+\s*\)`)
+			relaxed = re.ReplaceAllString(relaxed, "")
+		}
+		if relaxed == ddl {
+			continue
+		}
+
+		// DROP TABLE اس کے indexes/triggers بھی لے جاتا ہے: rename کے بعد وہی DDL دوبارہ
+		extras, err := sqliteTableExtras(ctx, conn, sc.table)
+		if err != nil {
+			return fmt.Errorf("%s: %v", sc.table, err)
+		}
+
+		tmp := sc.table + "_sealed_tmp"
+		createTmp := regexp.MustCompile(`(?i)^\s*CREATE\s+TABLE\s+"?`+sc.table+`"?`).ReplaceAllString(relaxed, "CREATE TABLE "+tmp)
+		stmts := []string{
+			"PRAGMA foreign_keys = OFF",
+			"BEGIN",
+			createTmp,
+			fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", tmp, sc.table),
+			"DROP TABLE " + sc.table,
+			fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmp, sc.table),
+		}
+		stmts = append(stmts, extras...)
+		stmts = append(stmts, "COMMIT", "PRAGMA foreign_keys = ON")
+		for _, stmt := range stmts {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				conn.ExecContext(ctx, "ROLLBACK")
+				conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+				return fmt.Errorf("%s: %v", sc.table, err)
+			}
+		}
+		fmt.Printf("🔒 [SEAL] Relaxed length checks on %s\n", sc.table)
+	}
+	return nil
+}
+
+// sqliteTableExtras ٹیبل کے explicit indexes اور triggers کی DDL (autoindexes کا sql NULL ہوتا ہے)
+func sqliteTableExtras(ctx context.Context, conn *sql.Conn, table string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT sql FROM sqlite_master WHERE type IN ('index', 'trigger') AND tbl_name = $1 AND sql IS NOT NULL ORDER BY type, name", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ddl := []string{}
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			return nil, err
+		}
+		ddl = append(ddl, stmt)
+	}
+	return ddl, rows.Err()
+}
+
+// tableExists صرف "ٹیبل نہیں" کو الگ کرتا ہے؛ lock یا schema کی غلطی واپس جاتی ہے
+func tableExists(raw *sql.DB, dialect, table string) (bool, error) {
+	var n int
+	var err error
+	if dialect == "postgres" {
+		err = raw.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1", table).Scan(&n)
+	} else {
+		err = raw.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = $1", table).Scan(&n)
+	}
+	return n > 0, err
+}
+
+// resealAll ہر sensitive ویلیو کو active key سے seal کرتا ہے
+// plaintext → sealed (پہلی بار کی migration)، پرانی key → نئی key (rotation)
+func resealAll(raw *sql.DB, dialect string) (int, error) {
+	total := 0
+	for _, sc := range sealedColumns {
+		exists, err := tableExists(raw, dialect, sc.table)
+		if err != nil {
+			return total, fmt.Errorf("%s: %v", sc.table, err)
+		}
+		if !exists {
+			continue
+		}
+		for _, col := range sc.columns {
+			n, err := resealColumn(raw, sc, col)
+			if err != nil {
+				return total, fmt.Errorf("%s.%s: %v", sc.table, col, err)
+			}
+			total += n
+		}
+	}
+	return total, nil
+}
+
+func resealColumn(raw *sql.DB, sc sealedColumn, col string) (int, error) {
+	rows, err := raw.Query(fmt.Sprintf("SELECT %s, %s FROM %s", strings.Join(sc.keys, ", "), col, sc.table))
+	if err != nil {
+		return 0, err
+	}
+	type update struct {
+		keys  []interface{}
+		value []byte
+	}
+	updates := []update{}
+	for rows.Next() {
+		keys := make([]interface{}, len(sc.keys))
+		ptrs := make([]interface{}, len(sc.keys)+1)
+		for i := range keys {
+			ptrs[i] = &keys[i]
+		}
+		var value []byte
+		ptrs[len(sc.keys)] = &value
+		if err := rows.Scan(ptrs...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if value == nil || (isSealed(value) && sealedKeyID(value) == sealActiveID) {
+			continue
+		}
+		plain := value
+		if isSealed(value) {
+			if plain, err = openValue(value); err != nil {
+				rows.Close()
+				return 0, err
+			}
+		}
+		sealed, err := sealValue(plain)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		updates = append(updates, update{keys, sealed})
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+	if len(updates) == 0 {
+		return 0, nil
+	}
+
+	where := []string{}
+	for i, k := range sc.keys {
+		where = append(where, fmt.Sprintf("%s = $%d", k, i+2))
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s = $1 WHERE %s", sc.table, col, strings.Join(where, " AND "))
+
+	tx, err := raw.Begin()
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range updates {
+		if _, err := tx.Exec(query, append([]interface{}{u.value}, u.keys...)...); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return len(updates), tx.Commit()
+}
+
+// hasSealedValues بغیر key کے sealed ڈیٹابیس کھولنے سے روکنے کے لیے
+// کوئی بھی غلطی (lock، schema) واپس، تاکہ شک کی حالت میں store نہ کھلے
+func hasSealedValues(raw *sql.DB, dialect string) (bool, error) {
+	for _, sc := range sealedColumns {
+		exists, err := tableExists(raw, dialect, sc.table)
+		if err != nil {
+			return false, fmt.Errorf("%s: %v", sc.table, err)
+		}
+		if !exists {
+			continue
+		}
+		for _, col := range sc.columns {
+			found, err := columnHasSealed(raw, sc.table, col)
+			if err != nil {
+				return false, fmt.Errorf("%s.%s: %v", sc.table, col, err)
+			}
+			if found {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func columnHasSealed(raw *sql.DB, table, col string) (bool, error) {
+	rows, err := raw.Query(fmt.Sprintf("SELECT %s FROM %s", col, table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v []byte
+		if err := rows.Scan(&v); err != nil {
+			return false, err
+		}
+		if isSealed(v) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// openSessionDB whatsmeow کے لیے DB: key ہو تو sealing driver، ورنہ عام driver
+// پہلی بار key لگانے پر plaintext ڈیٹا خود بخود seal ہو جاتا ہے (اور rotation پر نئی key سے)
+func openSessionDB(ctx context.Context, dialect, address string, dbLog waLog.Logger) (*sqlstore.Container, error) {
+	if err := ensureSealKeys(); err != nil {
+		return nil, err
+	}
+	if !sealEnabled() {
+		raw, err := sql.Open(dialect, address)
+		if err != nil {
+			return nil, err
+		}
+		sealedDB, err := hasSealedValues(raw, dialect)
+		raw.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Could not check session store for encrypted values: %v", err)
+		}
+		if sealedDB {
+			return nil, fmt.Errorf("Session store is encrypted but SESSION_ENCRYPTION_KEYS is not set")
+		}
+		return sqlstore.New(ctx, dialect, address, dbLog)
+	}
+
+	sealed, err := sql.Open(dialect+"-sealed", address)
+	if err != nil {
+		return nil, err
+	}
+	c := sqlstore.NewWithDB(sealed, dialect, dbLog)
+	if err := c.Upgrade(ctx); err != nil {
+		return nil, fmt.Errorf("failed to upgrade database: %w", err)
+	}
+
+	raw, err := sql.Open(dialect, address)
+	if err != nil {
+		return nil, err
+	}
+	defer raw.Close()
+	if err := relaxSealedConstraints(raw, dialect); err != nil {
+		return nil, err
+	}
+	n, err := resealAll(raw, dialect)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		fmt.Printf("🔒 [SEAL] Sealed %d session values with key %d\n", n, sealActiveID)
+	}
+	return c, nil
+}
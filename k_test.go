@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waAdv"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// testSealKeys ٹیسٹ کے لیے keys؛ پہلی active (nil = sealing بند)
+func testSealKeys(t *testing.T, ids ...byte) {
+	t.Helper()
+	if err := ensureSealKeys(); err != nil {
+		t.Fatal(err)
+	}
+	oldKeys, oldActive := sealKeys, sealActiveID
+	sealKeys, sealActiveID = map[byte][]byte{}, 0
+	for i, id := range ids {
+		sealKeys[id] = bytes.Repeat([]byte{id}, 32)
+		if i == 0 {
+			sealActiveID = id
+		}
+	}
+	t.Cleanup(func() { sealKeys, sealActiveID = oldKeys, oldActive })
+}
+
+func testSessionAddress(t *testing.T) string {
+	return "file:" + filepath.Join(t.TempDir(), "kami_sessions.db") + "?_foreign_keys=on"
+}
+
+var testDeviceJID = types.JID{User: "923001234567", Device: 1, Server: types.DefaultUserServer}
+
+// fillSessionStore whatsmeow کے ہر write راستے سے ایک ایک sealed ویلیو
+func fillSessionStore(t *testing.T, c *sqlstore.Container) *store.Device {
+	t.Helper()
+	ctx := context.Background()
+	dev := c.NewDevice()
+	jid := testDeviceJID
+	dev.ID = &jid
+	dev.Account = &waAdv.ADVSignedDeviceIdentity{
+		Details:             []byte{1},
+		AccountSignature:    make([]byte, 64),
+		AccountSignatureKey: make([]byte, 32),
+		DeviceSignature:     make([]byte, 64),
+	}
+	if err := c.PutDevice(ctx, dev); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.Sessions.PutSession(ctx, "923007654321.0", []byte("session-state")); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.SenderKeys.PutSenderKey(ctx, "120363001@g.us", "923007654321.0", []byte("sender-key")); err != nil {
+		t.Fatal(err)
+	}
+	err := dev.AppStateKeys.PutAppStateSyncKey(ctx, []byte("key-1"), store.AppStateSyncKey{Data: []byte("app-state-key"), Timestamp: time.Now().Unix(), Fingerprint: []byte("fp")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dev.PreKeys.GetOrGenPreKeys(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	return dev
+}
+
+// checkSessionStore دوبارہ کھول کر whatsmeow سے وہی ویلیوز واپس
+func checkSessionStore(t *testing.T, c *sqlstore.Container, want *store.Device) {
+	t.Helper()
+	ctx := context.Background()
+	dev, err := c.GetDevice(ctx, testDeviceJID)
+	if err != nil || dev == nil {
+		t.Fatalf("device = %v, %v", dev, err)
+	}
+	if *dev.NoiseKey.Priv != *want.NoiseKey.Priv || *dev.IdentityKey.Priv != *want.IdentityKey.Priv ||
+		*dev.SignedPreKey.Priv != *want.SignedPreKey.Priv || !bytes.Equal(dev.AdvSecretKey, want.AdvSecretKey) {
+		t.Fatal("device keys changed after round trip")
+	}
+	if got, err := dev.Sessions.GetSession(ctx, "923007654321.0"); err != nil || string(got) != "session-state" {
+		t.Fatalf("session = %q, %v", got, err)
+	}
+	if got, err := dev.SenderKeys.GetSenderKey(ctx, "120363001@g.us", "923007654321.0"); err != nil || string(got) != "sender-key" {
+		t.Fatalf("sender key = %q, %v", got, err)
+	}
+	if got, err := dev.AppStateKeys.GetAppStateSyncKey(ctx, []byte("key-1")); err != nil || got == nil || string(got.Data) != "app-state-key" {
+		t.Fatalf("app state key = %+v, %v", got, err)
+	}
+	if pk, err := dev.PreKeys.GetPreKey(ctx, 1); err != nil || pk == nil {
+		t.Fatalf("pre key = %v, %v", pk, err)
+	}
+}
+
+// rawSealedValues ہر sealed کالم کی غیر NULL ویلیوز، بغیر sealing driver کے
+func rawSealedValues(t *testing.T, address string) map[string][][]byte {
+	t.Helper()
+	raw, err := sql.Open("sqlite3", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	out := map[string][][]byte{}
+	for _, sc := range sealedColumns {
+		for _, col := range sc.columns {
+			rows, err := raw.Query("SELECT " + col + " FROM " + sc.table + " WHERE " + col + " IS NOT NULL")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for rows.Next() {
+				var v []byte
+				if err := rows.Scan(&v); err != nil {
+					t.Fatal(err)
+				}
+				out[sc.table+"."+col] = append(out[sc.table+"."+col], v)
+			}
+			rows.Close()
+		}
+	}
+	return out
+}
+
+// assertAllSealed کوئی plaintext نہ رہے اور سب اسی key سے
+func assertAllSealed(t *testing.T, address string, keyID byte) {
+	t.Helper()
+	values := rawSealedValues(t, address)
+	for _, sc := range sealedColumns {
+		for _, col := range sc.columns {
+			if len(values[sc.table+"."+col]) == 0 {
+				t.Fatalf("%s.%s: nothing written, test does not cover it", sc.table, col)
+			}
+		}
+	}
+	for name, list := range values {
+		for _, v := range list {
+			if !isSealed(v) {
+				t.Fatalf("%s stored in plaintext", name)
+			}
+			if sealedKeyID(v) != keyID {
+				t.Fatalf("%s sealed with key %d, want %d", name, sealedKeyID(v), keyID)
+			}
+		}
+	}
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	testSealKeys(t, 1)
+	address := testSessionAddress(t)
+	ctx := context.Background()
+
+	c, err := openSessionDB(ctx, "sqlite3", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := fillSessionStore(t, c)
+	c.Close()
+	assertAllSealed(t, address, 1)
+
+	c, err = openSessionDB(ctx, "sqlite3", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	checkSessionStore(t, c, dev)
+}
+
+func TestSealMigratesPlaintext(t *testing.T) {
+	testSealKeys(t)
+	address := testSessionAddress(t)
+	ctx := context.Background()
+
+	c, err := openSessionDB(ctx, "sqlite3", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := fillSessionStore(t, c)
+	c.Close()
+
+	// table rebuild کے بعد index اور trigger باقی رہیں
+	raw, _ := sql.Open("sqlite3", address)
+	for _, stmt := range []string{
+		"CREATE INDEX test_pre_keys_uploaded ON whatsmeow_pre_keys (uploaded)",
+		"CREATE TRIGGER test_pre_keys_noop AFTER DELETE ON whatsmeow_pre_keys BEGIN SELECT 1; END",
+	} {
+		if _, err := raw.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw.Close()
+
+	testSealKeys(t, 1)
+	c, err = openSessionDB(ctx, "sqlite3", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	assertAllSealed(t, address, 1)
+	checkSessionStore(t, c, dev)
+
+	raw, _ = sql.Open("sqlite3", address)
+	defer raw.Close()
+	for _, name := range []string{"test_pre_keys_uploaded", "test_pre_keys_noop"} {
+		var n int
+		if err := raw.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = $1", name).Scan(&n); err != nil || n != 1 {
+			t.Fatalf("%s lost in table rebuild (%d, %v)", name, n, err)
+		}
+	}
+}
+
+func TestSealRotatesKey(t *testing.T) {
+	testSealKeys(t, 1)
+	address := testSessionAddress(t)
+	ctx := context.Background()
+
+	c, err := openSessionDB(ctx, "sqlite3", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := fillSessionStore(t, c)
+	c.Close()
+
+	// نئی key active، پرانی صرف کھولنے کے لیے
+	testSealKeys(t, 2, 1)
+	c, err = openSessionDB(ctx, "sqlite3", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAllSealed(t, address, 2)
+	checkSessionStore(t, c, dev)
+	c.Close()
+
+	// rotation مکمل: پرانی key کے بغیر بھی کھلے
+	testSealKeys(t, 2)
+	c, err = openSessionDB(ctx, "sqlite3", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	checkSessionStore(t, c, dev)
+}
+
+func TestSealedStoreNeedsKey(t *testing.T) {
+	testSealKeys(t, 1)
+	address := testSessionAddress(t)
+	ctx := context.Background()
+
+	c, err := openSessionDB(ctx, "sqlite3", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillSessionStore(t, c)
+	c.Close()
+
+	testSealKeys(t)
+	if c, err := openSessionDB(ctx, "sqlite3", address, nil); err == nil {
+		c.Close()
+		t.Fatal("opened a sealed store without a key")
+	}
+}
+
+func TestUnsealedWriteRejected(t *testing.T) {
+	testSealKeys(t, 1)
+	address := testSessionAddress(t)
+	ctx := context.Background()
+
+	c, err := openSessionDB(ctx, "sqlite3", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	fillSessionStore(t, c)
+
+	sealed, err := sql.Open("sqlite3-sealed", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sealed.Close()
+	if _, err := sealed.Exec("UPDATE whatsmeow_sessions SET session = $1 WHERE our_jid = $2", []byte("plain"), testDeviceJID.String()); err == nil {
+		t.Fatal("UPDATE of a sealed column went through the sealing driver")
+	}
+	// غیر sealed کالم کا UPDATE ٹھیک ہے
+	if _, err := sealed.Exec("UPDATE whatsmeow_pre_keys SET uploaded = true WHERE jid = $1", testDeviceJID.String()); err != nil {
+		t.Fatal(err)
+	}
+	assertAllSealed(t, address, 1)
+}
+
+// TestWhatsmeowSealedWrites whatsmeow کی ہر SQL query جو sealed کالم لکھتی ہے
+// یا تو INSERT ... VALUES ہو (جو sealPlan پکڑے) یا sealed ٹیبل سے INSERT ... SELECT؛
+// کوئی اور شکل (UPDATE، literal) آئے تو نیا whatsmeow ورژن plaintext لکھ سکتا ہے
+func TestWhatsmeowSealedWrites(t *testing.T) {
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", "go.mau.fi/whatsmeow/store/sqlstore").Output()
+	if err != nil {
+		t.Skipf("go list: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(strings.TrimSpace(string(out)), "*.go"))
+	if len(files) == 0 {
+		t.Fatal("no whatsmeow sqlstore sources found")
+	}
+
+	insertSelectRe := regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+(\w+)\s*\(([^)]*)\)\s*SELECT\s.*?\sFROM\s+(\w+)`)
+	checked := 0
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			query, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return true
+			}
+			for _, sc := range sealedColumns {
+				if !regexp.MustCompile(`(?i)\b` + sc.table + `\b`).MatchString(query) {
+					continue
+				}
+				for _, col := range sc.columns {
+					colRe := regexp.MustCompile(`(?i)\b` + col + `\b`)
+					if !colRe.MatchString(query) {
+						continue
+					}
+					head := strings.ToUpper(strings.TrimSpace(query))
+					switch {
+					case strings.HasPrefix(head, "UPDATE"):
+						if unsealedWrite(query) != nil {
+							t.Errorf("%s: whatsmeow updates sealed %s.%s outside INSERT:\n%s", filepath.Base(file), sc.table, col, query)
+						}
+					case strings.HasPrefix(head, "INSERT"):
+						m := insertRe.FindStringSubmatch(query)
+						if m == nil {
+							if s := insertSelectRe.FindStringSubmatch(query); s != nil && strings.EqualFold(s[3], sc.table) {
+								checked++ // پہلے سے sealed ویلیو کی نقل
+								continue
+							}
+							t.Errorf("%s: INSERT into %s is not VALUES or a copy from itself:\n%s", filepath.Base(file), sc.table, query)
+							continue
+						}
+						if !strings.EqualFold(m[1], sc.table) || !colRe.MatchString(m[2]) {
+							continue
+						}
+						if len(sealPlan(query)) == 0 {
+							t.Errorf("%s: sealPlan misses %s.%s in:\n%s", filepath.Base(file), sc.table, col, query)
+						}
+						checked++
+					}
+				}
+			}
+			return true
+		})
+	}
+	if checked == 0 {
+		t.Fatal("no sealed writes found in whatsmeow sources")
+	}
+}
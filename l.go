@@ -1,73 +1,256 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
-//	"strings"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
 )
 
+// lidEntry LID → اصلی نمبر، اور یہ کہاں سے معلوم ہوا
+type lidEntry struct {
+	Phone  string
+	Source string // device | alt | store
+	Seen   time.Time
+}
+
 var (
-	LidMap   = make(map[string]string)
+	LidMap   = make(map[string]lidEntry)
 	LidMutex sync.RWMutex
 )
 
 func InitLIDSystem() {
-	db, err := sql.Open("sqlite3", "file:./data/kami_sessions.db?mode=ro") // Read Only Mode
-	if err != nil {
-		fmt.Println("⚠️ [LID] Could not open sessions DB:", err)
-		return
-	}
-	defer db.Close()
-
-	// ٹیبل سے ڈیٹا نکالیں
-	rows, err := db.Query("SELECT jid, lid FROM whatsmeow_device")
+	// کنفیگرڈ سیشن اسٹور (SQLite یا PostgreSQL) سے، فائل براہ راست نہیں کھولتے
+	devices, err := sessionStore().GetAllDevices(context.Background())
 	if err != nil {
-		fmt.Println("⚠️ [LID] Table query failed (Maybe no sessions yet):", err)
+		fmt.Println("⚠️ [LID] Could not load devices (Maybe no sessions yet):", err)
 		return
 	}
-	defer rows.Close()
 
 	count := 0
-	LidMutex.Lock()
-	for rows.Next() {
-		var rawJID, rawLID sql.NullString
-		if err := rows.Scan(&rawJID, &rawLID); err != nil {
+	for _, device := range devices {
+		if device.ID == nil || device.LID.IsEmpty() {
 			continue
 		}
+		trackSessionLID(device)
+		count++
+	}
 
-		if rawJID.Valid && rawLID.Valid && rawLID.String != "" {
-	
-			phone := getCleanID(rawJID.String)
-			lid := getCleanID(rawLID.String)
+	fmt.Printf("💎 [LID SYSTEM] Loaded %d linked identities into memory.\n", count)
+}
 
-			LidMap[lid] = phone
-			count++
-		
-		}
+func rememberLID(lid, phone, source string) {
+	lid, phone = getCleanID(lid), getCleanID(phone)
+	if lid == "" || phone == "" {
+		return
 	}
+	LidMutex.Lock()
+	LidMap[lid] = lidEntry{Phone: phone, Source: source, Seen: time.Now()}
 	LidMutex.Unlock()
+}
 
-	fmt.Printf("💎 [LID SYSTEM] Loaded %d linked identities into memory.\n", count)
+func forgetLID(lid string) {
+	LidMutex.Lock()
+	delete(LidMap, getCleanID(lid))
+	LidMutex.Unlock()
+}
+
+func cachedLID(lid string) (lidEntry, bool) {
+	LidMutex.RLock()
+	e, ok := LidMap[lid]
+	LidMutex.RUnlock()
+	return e, ok
 }
 
+// ResolveJID LID ہو تو اصلی نمبر (کیش → whatsmeow کا LID mapping اسٹور)، ورنہ صاف ID
 func ResolveJID(inputJID string) string {
 	cleanInput := getCleanID(inputJID)
 
-	LidMutex.RLock()
-	realPhone, exists := LidMap[cleanInput]
-	LidMutex.RUnlock()
+	if e, exists := cachedLID(cleanInput); exists {
+		return e.Phone
+	}
 
-	if exists {
-		// fmt.Printf("🔄 [RESOLVE] Swapped LID %s with Phone %s\n", cleanInput, realPhone)
-		return realPhone
+	// صرف @lid والے JIDs اسٹور سے پوچھیں؛ نہ ملے تو کیش نہ کریں (بعد میں mapping آ سکتی ہے)
+	jid, err := types.ParseJID(inputJID)
+	sc := sessionStore()
+	if err != nil || jid.Server != types.HiddenUserServer || sc == nil {
+		return cleanInput
 	}
+	pn, err := sc.LIDMap.GetPNForLID(context.Background(), jid.ToNonAD())
+	if err != nil || pn.IsEmpty() {
+		return cleanInput
+	}
+	rememberLID(jid.User, pn.User, "store")
+	return pn.User
+}
 
-	return cleanInput
+// ResolveSender کمانڈ بھیجنے والے کا اصلی نمبر (settings اسی پر keyed ہیں)
+// گروپس میں Sender اکثر LID ہوتا ہے، اس کا نمبر SenderAlt میں آ جاتا ہے
+func ResolveSender(cli *whatsmeow.Client, evt *events.Message) string {
+	// Note to self: بوٹ خود
+	if evt.Info.IsFromMe && cli.Store.ID != nil {
+		return getCleanID(cli.Store.ID.User)
+	}
+
+	sender, alt := evt.Info.Sender.ToNonAD(), evt.Info.SenderAlt.ToNonAD()
+	switch {
+	case sender.Server == types.HiddenUserServer && alt.Server == types.DefaultUserServer:
+		rememberLID(sender.User, alt.User, "alt")
+		return alt.User
+	case sender.Server == types.DefaultUserServer && alt.Server == types.HiddenUserServer:
+		rememberLID(alt.User, sender.User, "alt")
+		return sender.User
+	}
+	return ResolveJID(sender.String())
 }
 
-func RefreshLIDCache() {
-	go InitLIDSystem()
+// whoisJID .whois کے لیے: LID/نمبر دونوں طرف کی معلومات
+// admin کے علاوہ صرف اسی سیشن کے اپنے نمبر یا اس کے members کی
+func whoisJID(input, session string, admin bool) string {
+	if !strings.Contains(input, "@") {
+		input = normalizeNumber(input) + "@" + types.DefaultUserServer
+	}
+	jid, err := types.ParseJID(input)
+	if err != nil {
+		return "⚠️ Error: Invalid JID"
+	}
+	jid = jid.ToNonAD()
+	ctx := context.Background()
+
+	owner := ResolveJID(jid.String())
+	if !admin && !isSessionMember(session, owner) {
+		return "❌ You can only look up this session's own members."
+	}
+
+	msg := fmt.Sprintf("🔎 *Whois* `%s`\n", jid.String())
+	switch jid.Server {
+	case types.HiddenUserServer:
+		msg += "🏷️ *Type:* LID\n"
+		if e, ok := cachedLID(jid.User); ok {
+			msg += fmt.Sprintf("📞 *Phone:* `%s` (cache: %s, %s)\n", e.Phone, e.Source, formatAge(e.Seen))
+		} else if phone := ResolveJID(jid.String()); phone != jid.User {
+			msg += fmt.Sprintf("📞 *Phone:* `%s` (store)\n", phone)
+		} else {
+			msg += "📞 *Phone:* unknown\n"
+		}
+	case types.DefaultUserServer:
+		msg += "🏷️ *Type:* Phone\n"
+		if lid, err := sessionStore().LIDMap.GetLIDForPN(ctx, jid); err == nil && !lid.IsEmpty() {
+			msg += fmt.Sprintf("🆔 *LID:* `%s`\n", lid.User)
+		} else {
+			msg += "🆔 *LID:* unknown\n"
+		}
+	default:
+		msg += "🏷️ *Type:* " + jid.Server + "\n"
+	}
+
+	msg += fmt.Sprintf("📡 *Channels:* %d", len(GetUserSettings(owner).Channels))
+	ClientMutex.Lock()
+	_, isSession := ActiveClients[owner]
+	ClientMutex.Unlock()
+	if isSession {
+		msg += "\n🤖 *Session:* loaded"
+	}
+	return msg
+}
+
+// ---------------------------------------------------------
+// 🔄 EVENT-DRIVEN MAINTENANCE
+// ---------------------------------------------------------
+
+// trackSessionLID سیشن لوڈ/connect ہونے پر صرف اسی کی entry (پورا rebuild نہیں)
+func trackSessionLID(device *store.Device) {
+	if device.ID == nil || device.LID.IsEmpty() {
+		return
+	}
+	rememberLID(device.LID.User, device.ID.User, "device")
+}
+
+// evictSessionLIDs سیشن logout/delete ہو تو اس کی اپنی entries ہٹائیں
+func evictSessionLIDs(phone string) {
+	phone = getCleanID(phone)
+	LidMutex.Lock()
+	for lid, e := range LidMap {
+		if e.Phone == phone && e.Source == "device" {
+			delete(LidMap, lid)
+		}
+	}
+	LidMutex.Unlock()
+}
+
+// forgetPhone identity بدلنے پر اس نمبر کی سیکھی ہوئی (غیر-device) mappings ختم
+func forgetPhone(phone string) {
+	phone = getCleanID(phone)
+	LidMutex.Lock()
+	for lid, e := range LidMap {
+		if e.Phone == phone && e.Source != "device" {
+			delete(LidMap, lid)
+		}
+	}
+	LidMutex.Unlock()
+}
+
+func resetLIDCache() {
+	LidMutex.Lock()
+	LidMap = make(map[string]lidEntry)
+	LidMutex.Unlock()
+}
+
+// handleLIDEvent EventHandler سے ہر event پر
+func handleLIDEvent(cli *whatsmeow.Client, session string, evt interface{}) {
+	switch v := evt.(type) {
+	case *events.PairSuccess:
+		if !v.LID.IsEmpty() {
+			rememberLID(v.LID.User, v.ID.User, "device")
+		}
+	case *events.Connected:
+		// LID migration کے بعد Store.LID بعد میں بھی مل سکتا ہے
+		trackSessionLID(cli.Store)
+	case *events.LoggedOut:
+		evictSessionLIDs(session)
+	case *events.IdentityChange:
+		if v.JID.Server == types.HiddenUserServer {
+			forgetLID(v.JID.User)
+		} else {
+			forgetPhone(v.JID.User)
+		}
+	}
+}
+
+// lidCacheStats /health کے لیے؛ entries (LID → نمبر) صرف admin کو، باقی سب کو گنتی
+func lidCacheStats(full bool) map[string]interface{} {
+	LidMutex.RLock()
+	defer LidMutex.RUnlock()
+
+	bySource := map[string]int{}
+	for _, e := range LidMap {
+		bySource[e.Source]++
+	}
+	stats := map[string]interface{}{
+		"size":      len(LidMap),
+		"by_source": bySource,
+	}
+	if !full {
+		return stats
+	}
+
+	entries := make([]map[string]interface{}, 0, len(LidMap))
+	for lid, e := range LidMap {
+		entries = append(entries, map[string]interface{}{
+			"lid":    lid,
+			"phone":  e.Phone,
+			"source": e.Source,
+			"seen":   e.Seen.Unix(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i]["lid"].(string) < entries[j]["lid"].(string) })
+	stats["entries"] = entries
+	return stats
 }
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 🗄️ SCHEMA MIGRATIONS (kami_bot.db)
+// ---------------------------------------------------------
+
+// migration ہر ورژن ایک بار، ایک transaction میں چلتا ہے
+// نئی تبدیلی ہمیشہ لسٹ کے آخر میں نئے ورژن کے ساتھ، پرانی کبھی edit نہ کریں
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{1, "baseline", migrateBaseline},
+	{2, "channels_table", migrateChannelsTable},
+	{3, "sent_history_created_index", migrateSentHistoryIndex},
+	{4, "otp_history_search", migrateOTPHistorySearch},
+	{5, "session_prefixes", migrateSessionPrefixes},
+	{6, "session_members", migrateSessionMembers},
+	{7, "delivery_log_owner_index", migrateDeliveryLogIndex},
+	{8, "channel_schedule_columns", migrateChannelScheduleColumns},
+	{9, "prune_orphan_owners (withdrawn)", migrateNothing},
+	{10, "backup_consent", migrateBackupConsent},
+	{11, "delivery_log_kind", migrateDeliveryLogKind},
+	{12, "held_otp_attempts", migrateHeldOTPAttempts},
+}
+
+func runMigrations() error {
+	_, err := db.Exec(translateDDL(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT,
+		applied_at DATETIME
+	)`))
+	if err != nil {
+		return err
+	}
+
+	var current int
+	db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := m.up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Migration %d (%s) failed: %v", m.version, m.name, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)", m.version, m.name, time.Now()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		fmt.Printf("🗄️ [MIGRATION] Applied %d: %s\n", m.version, m.name)
+	}
+	return nil
+}
+
+// translateDDL SQLite کی DDL کو پوسٹگریس کے مطابق بدلتا ہے
+// (DML پہلے ہی دونوں پر چلنے والی لکھی گئی ہے: $1 placeholders، ON CONFLICT، RETURNING)
+func translateDDL(stmt string) string {
+	if dbDialect != "postgres" {
+		return stmt
+	}
+	return strings.NewReplacer(
+		"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+		"DATETIME", "TIMESTAMPTZ",
+	).Replace(stmt)
+}
+
+func execAll(tx *sql.Tx, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(translateDDL(stmt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// v1: وہ تمام ٹیبلز جو migrations سے پہلے InitDB بناتا تھا
+// IF NOT EXISTS تاکہ پرانے ڈیٹابیس پر بھی محفوظ چلے
+func migrateBaseline(tx *sql.Tx) error {
+	return execAll(tx,
+		// Table for User Settings (Channels & Links)
+		`CREATE TABLE IF NOT EXISTS user_settings (
+			jid TEXT PRIMARY KEY,
+			channels TEXT,
+			custom_link TEXT
+		)`,
+
+		// Table for Sent OTP History (Global Deduplication)
+		`CREATE TABLE IF NOT EXISTS sent_history (
+			msg_id TEXT PRIMARY KEY,
+			created_at DATETIME
+		)`,
+
+		// Table for Failover Sessions (owner -> backup)
+		`CREATE TABLE IF NOT EXISTS session_backups (
+			owner TEXT,
+			backup TEXT,
+			created_at DATETIME,
+			PRIMARY KEY (owner, backup)
+		)`,
+
+		// Table for Delivery Audit Log (which session delivered what)
+		`CREATE TABLE IF NOT EXISTS delivery_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			msg_id TEXT,
+			target TEXT,
+			owner TEXT,
+			session TEXT,
+			status TEXT,
+			error TEXT,
+			created_at DATETIME
+		)`,
+
+		// Table for Number Watches (private DM on matching OTP)
+		`CREATE TABLE IF NOT EXISTS watches (
+			watcher TEXT,
+			number TEXT,
+			dm_jid TEXT,
+			session TEXT,
+			created_at DATETIME,
+			PRIMARY KEY (watcher, number)
+		)`,
+
+		// Table for OTP History (lookup via .otp / API)
+		`CREATE TABLE IF NOT EXISTS otp_history (
+			msg_id TEXT PRIMARY KEY,
+			source INTEGER,
+			country TEXT,
+			phone TEXT,
+			service TEXT,
+			code TEXT,
+			body TEXT,
+			raw_time TEXT,
+			created_at DATETIME
+		)`,
+
+		// Table for HTTP API Tokens (one per user)
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			token TEXT PRIMARY KEY,
+			owner TEXT UNIQUE,
+			created_at DATETIME
+		)`,
+
+		// Table for Source Subscriptions (channel = '' means all channels)
+		`CREATE TABLE IF NOT EXISTS source_subs (
+			owner TEXT,
+			channel TEXT,
+			source INTEGER,
+			PRIMARY KEY (owner, channel, source)
+		)`,
+
+		// Table for Outbound Webhooks
+		`CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner TEXT,
+			url TEXT,
+			secret TEXT,
+			created_at DATETIME,
+			UNIQUE (owner, url)
+		)`,
+
+		// Table for Webhook Delivery Log (one row per attempt)
+		`CREATE TABLE IF NOT EXISTS webhook_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER,
+			event TEXT,
+			attempt INTEGER,
+			status_code INTEGER,
+			error TEXT,
+			created_at DATETIME
+		)`,
+
+		// Table for External Sinks (Telegram / Discord)
+		`CREATE TABLE IF NOT EXISTS sinks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner TEXT,
+			kind TEXT,
+			target TEXT,
+			token TEXT,
+			template TEXT,
+			created_at DATETIME
+		)`,
+
+		// Table for Per-Channel Delivery Schedules
+		`CREATE TABLE IF NOT EXISTS channel_schedules (
+			owner TEXT,
+			channel TEXT,
+			days TEXT,
+			start_hour INTEGER,
+			end_hour INTEGER,
+			tz TEXT,
+			mode TEXT,
+			PRIMARY KEY (owner, channel)
+		)`,
+
+		// Table for OTPs held outside schedule (sent later as digest)
+		`CREATE TABLE IF NOT EXISTS held_otps (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner TEXT,
+			channel TEXT,
+			msg_id TEXT,
+			body TEXT,
+			created_at DATETIME
+		)`,
+
+		`CREATE INDEX IF NOT EXISTS idx_otp_history_phone ON otp_history (phone, created_at)`,
+	)
+}
+
+// v2: چینلز user_settings.channels (JSON) سے الگ ٹیبل میں
+// تاکہ "یہ JID کون target کر رہا ہے" query ہو سکے اور per-channel کالمز آ سکیں
+// پرانا channels کالم legacy کے طور پر رہتا ہے مگر اب پڑھا نہیں جاتا
+func migrateChannelsTable(tx *sql.Tx) error {
+	err := execAll(tx,
+		`CREATE TABLE IF NOT EXISTS channels (
+			owner TEXT,
+			jid TEXT,
+			created_at DATETIME,
+			PRIMARY KEY (owner, jid)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_channels_jid ON channels (jid)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT jid, channels FROM user_settings")
+	if err != nil {
+		return err
+	}
+	type legacy struct {
+		owner    string
+		channels []string
+	}
+	list := []legacy{}
+	for rows.Next() {
+		var owner string
+		var raw sql.NullString
+		if rows.Scan(&owner, &raw) != nil || !raw.Valid {
+			continue
+		}
+		l := legacy{owner: owner}
+		json.Unmarshal([]byte(raw.String), &l.channels)
+		list = append(list, l)
+	}
+	rows.Close()
+
+	moved := 0
+	now := time.Now()
+	for _, l := range list {
+		for i, ch := range l.channels {
+			// ترتیب برقرار رکھنے کے لیے ہر چینل کو ایک سیکنڈ آگے
+			_, err := tx.Exec("INSERT INTO channels (owner, jid, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+				l.owner, ch, now.Add(time.Duration(i)*time.Second))
+			if err != nil {
+				return err
+			}
+			moved++
+		}
+	}
+	fmt.Printf("🗄️ [MIGRATION] Moved %d channels from JSON into channels table\n", moved)
+	return nil
+}
+
+// v3: retention pruning created_at پر range scan کرتا ہے
+func migrateSentHistoryIndex(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE INDEX IF NOT EXISTS idx_sent_history_created ON sent_history (created_at)`,
+	)
+}
+
+// v4: OTP ہسٹری کی تلاش کے لیے indexes اور full-text
+// SQLite: FTS4 ٹیبل (docid = otp_history.rowid) triggers کے ساتھ
+// PostgreSQL: tsvector پر GIN index
+func migrateOTPHistorySearch(tx *sql.Tx) error {
+	err := execAll(tx,
+		`CREATE INDEX IF NOT EXISTS idx_otp_history_created ON otp_history (created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_otp_history_service ON otp_history (service, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_otp_history_country ON otp_history (country, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_otp_history_source ON otp_history (source, created_at)`,
+	)
+	if err != nil {
+		return err
+	}
+
+	if dbDialect == "postgres" {
+		return execAll(tx,
+			`CREATE INDEX IF NOT EXISTS idx_otp_history_fts ON otp_history USING GIN (`+otpSearchVector+`)`,
+		)
+	}
+
+	return execAll(tx,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS otp_history_fts USING fts4 (phone, service, country, code, body)`,
+		`CREATE TRIGGER IF NOT EXISTS otp_history_fts_insert AFTER INSERT ON otp_history BEGIN
+			INSERT INTO otp_history_fts (docid, phone, service, country, code, body)
+			VALUES (new.rowid, new.phone, new.service, new.country, new.code, new.body);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS otp_history_fts_delete AFTER DELETE ON otp_history BEGIN
+			DELETE FROM otp_history_fts WHERE docid = old.rowid;
+		END`,
+		// پرانی ہسٹری بھی تلاش میں آئے
+		`INSERT INTO otp_history_fts (docid, phone, service, country, code, body)
+			SELECT rowid, phone, service, country, code, body FROM otp_history`,
+	)
+}
+
+// v5: ہر سیشن کا اپنا کمانڈ prefix (نہ ہو تو CommandPrefix)
+func migrateSessionPrefixes(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS session_prefixes (
+			session TEXT PRIMARY KEY,
+			prefix TEXT NOT NULL
+		)`,
+	)
+}
+
+// v6: سیشن کے ساتھی (delegated admin / viewer)؛ owner سیشن کا اپنا نمبر ہے
+func migrateSessionMembers(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE IF NOT EXISTS session_members (
+			session TEXT NOT NULL,
+			member TEXT NOT NULL,
+			role TEXT NOT NULL,
+			created_at DATETIME,
+			PRIMARY KEY (session, member)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_session_members_member ON session_members (member)`,
+	)
+}
+
+// v7: .status ہر مالک کی آخری/ناکام ڈیلیوریز پوچھتا ہے
+func migrateDeliveryLogIndex(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE INDEX IF NOT EXISTS idx_delivery_log_owner ON delivery_log (owner, created_at)`,
+	)
+}
+
+// v8: شیڈول (per-channel setting) الگ channel_schedules ٹیبل سے channels کے اپنے کالمز میں
+// جن شیڈولز کا چینل active نہیں وہ کبھی لاگو نہیں ہوتے تھے، وہ اور ان کے held OTPs ختم
+func migrateChannelScheduleColumns(tx *sql.Tx) error {
+	err := execAll(tx,
+		`ALTER TABLE channels ADD COLUMN days TEXT`,
+		`ALTER TABLE channels ADD COLUMN start_hour INTEGER`,
+		`ALTER TABLE channels ADD COLUMN end_hour INTEGER`,
+		`ALTER TABLE channels ADD COLUMN tz TEXT`,
+		`ALTER TABLE channels ADD COLUMN mode TEXT`,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT owner, channel, days, start_hour, end_hour, tz, mode FROM channel_schedules")
+	if err != nil {
+		return err
+	}
+	list := []Schedule{}
+	for rows.Next() {
+		var s Schedule
+		if rows.Scan(&s.Owner, &s.Channel, &s.Days, &s.StartHour, &s.EndHour, &s.TZ, &s.Mode) == nil {
+			list = append(list, s)
+		}
+	}
+	rows.Close()
+
+	moved := 0
+	for _, s := range list {
+		res, err := tx.Exec("UPDATE channels SET days = $1, start_hour = $2, end_hour = $3, tz = $4, mode = $5 WHERE owner = $6 AND jid = $7",
+			s.Days, s.StartHour, s.EndHour, s.TZ, s.Mode, s.Owner, s.Channel)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			moved++
+		}
+	}
+
+	res, err := tx.Exec(`DELETE FROM held_otps WHERE NOT EXISTS (SELECT 1 FROM channels c
+		WHERE c.owner = held_otps.owner AND c.jid = held_otps.channel AND c.mode = 'hold')`)
+	if err != nil {
+		return err
+	}
+	orphans, _ := res.RowsAffected()
+	if err := execAll(tx, `DROP TABLE channel_schedules`); err != nil {
+		return err
+	}
+	fmt.Printf("🗄️ [MIGRATION] Moved %d/%d schedules into channels (%d orphaned held OTPs removed)\n", moved, len(list), orphans)
+	return nil
+}
+
+// v9 پہلے orphan watches/webhooks/sinks ہٹاتا تھا؛ واپس لیا گیا (عارضی logout یا ADMIN_NUMBERS
+// کی تبدیلی پر users کی settings ہمیشہ کے لیے جاتیں)۔ نمبر دوبارہ استعمال نہیں ہوتا، اب کچھ نہیں کرتا
+func migrateNothing(tx *sql.Tx) error {
+	return nil
+}
+
+// v10: backup سیشن کے مالک کی رضامندی؛ accepted_at خالی = درخواست زیر التوا (failover میں شامل نہیں)
+// پرانی backups بغیر رضامندی کے بنی تھیں، اس لیے وہ بھی زیر التوا
+func migrateBackupConsent(tx *sql.Tx) error {
+	if err := execAll(tx, `ALTER TABLE session_backups ADD COLUMN accepted_at DATETIME`); err != nil {
+		return err
+	}
+	var pending int
+	tx.QueryRow("SELECT COUNT(*) FROM session_backups").Scan(&pending)
+	if pending > 0 {
+		fmt.Printf("🗄️ [MIGRATION] %d existing backups now wait for the backup owner's .backup accept\n", pending)
+	}
+	return nil
+}
+
+// v11: delivery_log.kind — whatsapp (سیشن سے) یا telegram/discord (sinks)؛ پہلے sinks کا kind
+// session کالم میں جاتا تھا اور .status کے سیشن اعداد میں گنا جاتا تھا
+func migrateDeliveryLogKind(tx *sql.Tx) error {
+	err := execAll(tx,
+		`ALTER TABLE delivery_log ADD COLUMN kind TEXT DEFAULT 'whatsapp'`,
+		`UPDATE delivery_log SET kind = 'whatsapp' WHERE kind IS NULL`,
+	)
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec("UPDATE delivery_log SET kind = session, session = '' WHERE session IN ('telegram', 'discord')")
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		fmt.Printf("🗄️ [MIGRATION] Marked %d sink deliveries in delivery_log\n", n)
+	}
+	return nil
+}
+
+// v12: digest کی ناکام کوششوں کی گنتی (ہمیشہ کی retry کے بجائے حد کے بعد drop)
+func migrateHeldOTPAttempts(tx *sql.Tx) error {
+	return execAll(tx,
+		`ALTER TABLE held_otps ADD COLUMN attempts INTEGER DEFAULT 0`,
+		`UPDATE held_otps SET attempts = 0 WHERE attempts IS NULL`,
+	)
+}
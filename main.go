@@ -7,8 +7,10 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,37 +21,38 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var sessionsDBPath = "./data/kami_sessions.db"
+
 var (
-	container     *sqlstore.Container
+	container     atomic.Pointer[sqlstore.Container] // restore پر بدلتا ہے، ہمیشہ sessionStore() سے پڑھیں
 	ActiveClients = make(map[string]*whatsmeow.Client)
 	ClientMutex   sync.Mutex
 )
 
 func main() {
+	// CLI Mode: ./bot backup <file> | ./bot restore <file>
+	if len(os.Args) > 1 {
+		runCLI(os.Args[1:])
+		return
+	}
+
 	fmt.Println("🚀 Starting Kami OTP Bot (Multi-Session)...")
 
 	// 1. Initialize Database Tables (Make sure database.go is present)
 	InitDB()
 
 	// 2. Initialize Whatsmeow Container (SQLite for Volume)
-	dbLog := waLog.Stdout("Database", "ERROR", true)
-	var err error
-	
-	// Railway Volume Path: ./data/
-	os.MkdirAll("./data", 0755) 
-	
-	// 🔥 FIX: Added context.Background() here
-	container, err = sqlstore.New(context.Background(), "sqlite3", "file:./data/kami_sessions.db?_foreign_keys=on", dbLog)
-	if err != nil {
-		panic("❌ Failed to initialize SQLite: " + err.Error())
-	}
+	initSessionStore()
 
 	// 3. Load Existing Sessions
 	StartAllBots()
 	InitLIDSystem()
 
 	// 4. Start OTP Monitor (Make sure otp.go is present)
+	StartWebhookWorkers()
 	go StartOTPMonitor()
+	go StartDigestJob()
+	go StartPruneJob()
 
 	// 5. Setup HTTP Server
 	port := os.Getenv("PORT")
@@ -66,6 +69,19 @@ func main() {
 	http.HandleFunc("/link/pair/", handlePairAPILegacy) // GET /link/pair/92300...
 	http.HandleFunc("/link/delete", handleDeleteSession)
 
+	http.HandleFunc("/health", handleHealth)
+
+	// Admin Routes (ADMIN_TOKEN)
+	http.HandleFunc("/admin/backup", handleBackupAPI)   // GET  → encrypted archive
+	http.HandleFunc("/admin/restore", handleRestoreAPI) // POST ← encrypted archive
+
+	// Query Routes (Token Auth)
+	http.HandleFunc("/api/otp", handleOTPLookupAPI) // GET ?number=...
+	http.HandleFunc("/api/search", handleSearchAPI) // GET ?q=&country=&service=&page=
+	http.HandleFunc("/api/export", handleExportAPI) // GET ?type=otp|deliveries&format=csv|json
+	http.HandleFunc("/api/stream", handleStreamSSE) // Server-Sent Events
+	http.HandleFunc("/api/ws", handleStreamWS)      // WebSocket
+
 	// Start Server
 	go func() {
 		fmt.Printf("🌐 Server listening on :%s\n", port)
@@ -87,13 +103,46 @@ func main() {
 	ClientMutex.Unlock()
 }
 
+func sessionStore() *sqlstore.Container {
+	return container.Load()
+}
+
+// initSessionStore whatsmeow کا اسٹور (SQLite فائل یا DATABASE_URL والا PostgreSQL)
+func initSessionStore() {
+	if err := openSessionStore(); err != nil {
+		panic("❌ " + err.Error())
+	}
+}
+
+// openSessionStore restore میں بھی: غلطی پر panic نہیں، error واپس
+func openSessionStore() error {
+	dbLog := waLog.Stdout("Database", "ERROR", true)
+
+	// Railway Volume Path: ./data/
+	os.MkdirAll(filepath.Dir(sessionsDBPath), 0755)
+
+	// 🔥 FIX: Added context.Background() here
+	// DATABASE_URL ہو تو سیشنز بھی اسی PostgreSQL میں
+	sessDialect, sessAddress := "sqlite3", "file:"+sessionsDBPath+"?_foreign_keys=on"
+	if DatabaseURL != "" {
+		sessDialect, sessAddress = "postgres", DatabaseURL
+	}
+	// SESSION_ENCRYPTION_KEYS ہو تو private keys / sessions encrypted محفوظ ہوتے ہیں
+	sc, err := openSessionDB(context.Background(), sessDialect, sessAddress, dbLog)
+	if err != nil {
+		return fmt.Errorf("Failed to initialize session store (%s): %v", sessDialect, err)
+	}
+	container.Store(sc)
+	return nil
+}
+
 // ---------------------------------------------------------
 // 🔄 MULTI-SESSION CORE LOGIC
 // ---------------------------------------------------------
 
 func StartAllBots() {
 	// 🔥 FIX: Added context.Background()
-	devices, err := container.GetAllDevices(context.Background())
+	devices, err := sessionStore().GetAllDevices(context.Background())
 	if err != nil {
 		fmt.Printf("❌ Could not load sessions: %v\n", err)
 		return
@@ -122,7 +171,7 @@ func ConnectNewSession(device *store.Device) {
 	newBot := whatsmeow.NewClient(device, clientLog)
 
 	// Hook the Handler (From handler.go)
-	newBot.AddEventHandler(EventHandler(newBot))
+	newBot.AddEventHandler(EventHandler(newBot, cleanID))
 
 	if err := newBot.Connect(); err != nil {
 		fmt.Printf("❌ Failed to connect %s: %v\n", cleanID, err)
@@ -135,7 +184,7 @@ func ConnectNewSession(device *store.Device) {
 	ActiveClients[cleanID] = newBot
 	ClientMutex.Unlock()
 	fmt.Printf("✅ [LOADED] Session: %s\n", cleanID)
-	RefreshLIDCache()
+	trackSessionLID(device)
 }
 
 // ---------------------------------------------------------
@@ -175,9 +224,7 @@ func handlePairAPIPost(w http.ResponseWriter, r *http.Request) {
 
 // Common Logic for Pairing
 func performPairing(w http.ResponseWriter, rawNumber string) {
-	number := strings.ReplaceAll(rawNumber, "+", "")
-	number = strings.ReplaceAll(number, " ", "")
-	number = strings.ReplaceAll(number, "-", "")
+	number := normalizeNumber(rawNumber)
 	cleanNum := getCleanID(number)
 
 	fmt.Printf("📱 [PAIRING] Request for: %s\n", cleanNum)
@@ -192,20 +239,22 @@ func performPairing(w http.ResponseWriter, rawNumber string) {
 
 	// 2. Cleanup Old Session (Database)
 	// 🔥 FIX: Added context.Background()
-	devices, _ := container.GetAllDevices(context.Background())
+	devices, _ := sessionStore().GetAllDevices(context.Background())
 	for _, dev := range devices {
 		if getCleanID(dev.ID.User) == cleanNum {
+			// پرانے device کی LID entries بھی، ورنہ نیا pairing پرانی LID پر resolve ہو
+			evictSessionLIDs(dev.ID.User)
 			// 🔥 FIX: Added context.Background()
 			dev.Delete(context.Background())
 		}
 	}
 
 	// 3. Create New Device
-	device := container.NewDevice()
+	device := sessionStore().NewDevice()
 	client := whatsmeow.NewClient(device, waLog.Stdout("Pairing", "INFO", true))
 	
 	// Handler Add karein
-	client.AddEventHandler(EventHandler(client))
+	client.AddEventHandler(EventHandler(client, cleanNum))
 
 	if err := client.Connect(); err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"Connect failed: %v"}`, err), 500)
@@ -260,8 +309,11 @@ func handleDeleteSession(w http.ResponseWriter, r *http.Request) {
 
 	// Delete DB
 	// 🔥 FIX: Added context.Background()
-	devs, _ := container.GetAllDevices(context.Background())
+	devs, _ := sessionStore().GetAllDevices(context.Background())
 	for _, d := range devs {
+		if d.ID != nil {
+			evictSessionLIDs(d.ID.User)
+		}
 		d.Delete(context.Background())
 	}
 	
@@ -284,6 +336,14 @@ func servePicture(w http.ResponseWriter, r *http.Request) {
 // 🛠️ UTILS
 // ---------------------------------------------------------
 
+// normalizeNumber "+92 300-1234567" → "923001234567"
+func normalizeNumber(raw string) string {
+	number := strings.ReplaceAll(raw, "+", "")
+	number = strings.ReplaceAll(number, " ", "")
+	number = strings.ReplaceAll(number, "-", "")
+	return number
+}
+
 func getCleanID(id string) string {
 	if strings.Contains(id, ":") {
 		id = strings.Split(id, ":")[0]
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// ---------------------------------------------------------
+// 📣 NOTIFIERS (WhatsApp / Telegram / Discord)
+// ---------------------------------------------------------
+
+// Notifier کوئی بھی جگہ جہاں OTP کا متن بھیجا جا سکے
+type Notifier interface {
+	Kind() string
+	Notify(ctx context.Context, text string) error
+}
+
+// WhatsAppNotifier ایک سیشن کے ذریعے ایک گروپ/چینل/چیٹ پر (Forwarded اسٹائل)
+type WhatsAppNotifier struct {
+	Client *whatsmeow.Client
+	Target string
+}
+
+func (n WhatsAppNotifier) Kind() string { return "whatsapp" }
+
+func (n WhatsAppNotifier) Notify(ctx context.Context, text string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return sendForwarded(ctx, n.Client, n.Target, text)
+}
+
+// TelegramNotifier Bot API کا sendMessage
+// BaseURL خالی ہو تو https://api.telegram.org (sinks کے لیے TELEGRAM_API_URL، ٹیسٹ میں لوکل سرور)
+type TelegramNotifier struct {
+	BaseURL string
+	Token   string
+	ChatID  string
+	HTTP    *http.Client
+}
+
+func (n TelegramNotifier) Kind() string { return "telegram" }
+
+func (n TelegramNotifier) Notify(ctx context.Context, text string) error {
+	base := n.BaseURL
+	if base == "" {
+		base = "https://api.telegram.org"
+	}
+	payload := map[string]interface{}{
+		"chat_id":                  n.ChatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}
+	return postJSON(ctx, n.HTTP, strings.TrimSuffix(base, "/")+"/bot"+n.Token+"/sendMessage", payload)
+}
+
+// DiscordNotifier Discord Webhook URL پر content
+type DiscordNotifier struct {
+	WebhookURL string
+	HTTP       *http.Client
+}
+
+func (n DiscordNotifier) Kind() string { return "discord" }
+
+func (n DiscordNotifier) Notify(ctx context.Context, text string) error {
+	// Discord کی حد 2000 حروف
+	if len([]rune(text)) > 2000 {
+		text = string([]rune(text)[:1997]) + "..."
+	}
+	return postJSON(ctx, n.HTTP, n.WebhookURL, map[string]string{"content": text})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 300))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// newSinkNotifier ڈیٹابیس والے Sink سے Notifier
+func newSinkNotifier(s Sink) (Notifier, error) {
+	switch s.Kind {
+	case "telegram":
+		return TelegramNotifier{BaseURL: TelegramAPIURL, Token: s.Token, ChatID: s.Target}, nil
+	case "discord":
+		return DiscordNotifier{WebhookURL: s.Target}, nil
+	}
+	return nil, fmt.Errorf("Unknown sink kind: %s", s.Kind)
+}
+
+// ---------------------------------------------------------
+// 🧩 TEMPLATES
+// ---------------------------------------------------------
+
+const DefaultSinkTemplate = "{flag} {service} | {source}\n" +
+	"Time: {time}\n" +
+	"Country: {country}\n" +
+	"Number: {number}\n" +
+	"OTP: {code}\n\n" +
+	"{message}"
+
+// renderTemplate {placeholders} کو OTP کی ویلیوز سے بدلتا ہے
+// fullNumber نہ ہو تو {full_number} بھی masked
+func renderTemplate(tpl string, rec OTPRecord, cFlag, link string, fullNumber bool) string {
+	if strings.TrimSpace(tpl) == "" {
+		tpl = DefaultSinkTemplate
+	}
+	full := rec.Phone
+	if !fullNumber {
+		full = maskPhoneNumber(rec.Phone)
+	}
+	return strings.NewReplacer(
+		"{flag}", cFlag,
+		"{country}", rec.Country,
+		"{service}", rec.Service,
+		"{SERVICE}", strings.ToUpper(rec.Service),
+		"{number}", maskPhoneNumber(rec.Phone),
+		"{full_number}", full,
+		"{code}", rec.Code,
+		"{time}", rec.RawTime,
+		"{source}", sourceName(rec.Source),
+		"{message}", rec.Body,
+		"{link}", link,
+	).Replace(tpl)
+}
+
+// deliverSinks ہر user کے Telegram/Discord sinks پر (الگ goroutines میں)
+// owners: جن تک یہ OTP اپنے چینلز سے پہنچا (planOwners)؛ باقی صرف bot admins
+func deliverSinks(rec OTPRecord, cFlag string, owners map[string]bool) {
+	subsCache := map[string]SourceSubs{}
+	for _, s := range GetSinks("") {
+		subs, ok := subsCache[s.Owner]
+		if !ok {
+			subs = GetSourceSubs(s.Owner)
+			subsCache[s.Owner] = subs
+		}
+		if !owners[s.Owner] && !(isAdmin(s.Owner) && subs.Allows("", rec.Source)) {
+			continue
+		}
+
+		n, err := newSinkNotifier(s)
+		if err != nil {
+			fmt.Printf("      ⚠️ [SINK] #%d %v\n", s.ID, err)
+			continue
+		}
+		text := renderTemplate(s.Template, rec, cFlag, GetUserSettings(s.Owner).CustomLink, canViewNumber(s.Owner, rec.Phone))
+		label := fmt.Sprintf("%s:#%d", s.Kind, s.ID)
+
+		go func(s Sink, n Notifier, text, label string) {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			err := n.Notify(ctx, text)
+			LogSinkDelivery(rec.MsgID, label, s.Owner, n.Kind(), err)
+			PublishDeliveryEvent(rec, label, s.Owner, n.Kind(), err)
+			if err != nil {
+				fmt.Printf("      ❌ [SINK] %s FAILED: %v\n", label, err)
+			}
+		}(s, n, text, label)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// standIn لوکل Telegram/Discord سرور جو آخری request محفوظ کرتا ہے
+func standIn(t *testing.T, status int) (*httptest.Server, *http.Request, map[string]interface{}) {
+	t.Helper()
+	var got http.Request
+	body := map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = *r
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ok":false,"description":"stand-in"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got, body
+}
+
+func TestTelegramSinkUsesBaseURL(t *testing.T) {
+	srv, got, body := standIn(t, http.StatusOK)
+	old := TelegramAPIURL
+	TelegramAPIURL = srv.URL
+	defer func() { TelegramAPIURL = old }()
+
+	n, err := newSinkNotifier(Sink{Kind: "telegram", Token: "123:abc", Target: "-10042"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/bot123:abc/sendMessage" || got.Method != "POST" {
+		t.Fatalf("unexpected request %s %s", got.Method, got.URL.Path)
+	}
+	if body["chat_id"] != "-10042" || body["text"] != "hello" {
+		t.Fatalf("unexpected payload %v", body)
+	}
+}
+
+func TestDiscordSinkTruncates(t *testing.T) {
+	srv, _, body := standIn(t, http.StatusNoContent)
+	n, err := newSinkNotifier(Sink{Kind: "discord", Target: srv.URL + "/api/webhooks/1/x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), strings.Repeat("٣", 2500)); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := body["content"].(string)
+	if n := len([]rune(content)); n != 2000 || !strings.HasSuffix(content, "...") {
+		t.Fatalf("content not truncated to 2000 runes: %d", n)
+	}
+}
+
+func TestSinkHTTPError(t *testing.T) {
+	srv, _, _ := standIn(t, http.StatusBadRequest)
+	err := DiscordNotifier{WebhookURL: srv.URL}.Notify(context.Background(), "x")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 400") || !strings.Contains(err.Error(), "stand-in") {
+		t.Fatalf("expected HTTP 400 error with body, got %v", err)
+	}
+}
+
+func TestSinkRespectsContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := TelegramNotifier{BaseURL: srv.URL, Token: "t", ChatID: "1"}.Notify(ctx, "x")
+	if err == nil || time.Since(start) > 5*time.Second {
+		t.Fatalf("expected context deadline error, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestWhatsAppNotifierRespectsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Client nil: منسوخ context پر کلائنٹ تک پہنچنا ہی نہیں چاہیے
+	if err := (WhatsAppNotifier{Target: "123@g.us"}).Notify(ctx, "x"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestUnknownSinkKind(t *testing.T) {
+	if _, err := newSinkNotifier(Sink{Kind: "slack"}); err == nil {
+		t.Fatal("expected error for unknown sink kind")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/types"
 	"google.golang.org/protobuf/proto"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
@@ -21,19 +22,27 @@ const (
 func StartOTPMonitor() {
 	fmt.Println("👀 OTP Monitor Started... (Checking every 10s)")
 	for {
-		for i, url := range Config.OTPApiURLs {
-			apiIdx := i + 1
-			processAPI(url, apiIdx)
-		}
+		pollSources()
 		time.Sleep(time.Duration(Config.Interval) * time.Second)
 	}
 }
 
+// pollSources تمام APIs کا ایک چکر؛ restore کے دوران رکا رہتا ہے
+func pollSources() {
+	restoreGate.RLock()
+	defer restoreGate.RUnlock()
+	for i, url := range Config.OTPApiURLs {
+		apiIdx := i + 1
+		processAPI(url, apiIdx)
+	}
+}
+
 func processAPI(url string, apiIdx int) {
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Get(url)
 	if err != nil {
 		fmt.Printf("❌ API %d Error: %v\n", apiIdx, err)
+		recordSourcePoll(apiIdx, 0, err)
 		return
 	}
 	defer resp.Body.Close()
@@ -41,13 +50,20 @@ func processAPI(url string, apiIdx int) {
 	var data map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
 		fmt.Printf("❌ API %d JSON Error: %v\n", apiIdx, err)
+		recordSourcePoll(apiIdx, 0, fmt.Errorf("Invalid JSON (HTTP %d)", resp.StatusCode))
 		return
 	}
 
 	if data["aaData"] == nil {
+		recordSourcePoll(apiIdx, 0, fmt.Errorf("No aaData in response"))
 		return
 	}
-	aaData := data["aaData"].([]interface{})
+	aaData, _ := data["aaData"].([]interface{})
+	recordSourcePoll(apiIdx, len(aaData), nil)
+
+	// اس poll میں موجود تمام IDs (pruning انہیں نہیں ہٹائے گی)
+	seen := make(map[string]bool, len(aaData))
+	defer setLiveFeedIDs(apiIdx, seen)
 
 	for _, row := range aaData {
 		r, ok := row.([]interface{})
@@ -66,6 +82,7 @@ func processAPI(url string, apiIdx int) {
 		}
 
 		msgID := fmt.Sprintf("%v_%v", phone, rawTime)
+		seen[msgID] = true
 
 		if IsOTPSent(msgID) {
 			continue
@@ -80,69 +97,88 @@ func processAPI(url string, apiIdx int) {
 		maskedPhone := maskPhoneNumber(phone)
 		flatMsg := strings.ReplaceAll(strings.ReplaceAll(fullMsg, "\n", " "), "\r", "")
 
-		ClientMutex.Lock()
-		
-		activeSessionCount := 0
+		record := OTPRecord{
+			MsgID:     msgID,
+			Source:    apiIdx,
+			Country:   cleanCountry,
+			Phone:     normalizeNumber(phone),
+			Service:   service,
+			Code:      otpCode,
+			Body:      fullMsg,
+			RawTime:   rawTime,
+			CreatedAt: time.Now(),
+		}
+		SaveOTPRecord(record)
+
+		plan := planDeliveries(apiIdx)
+		if len(plan) == 0 {
+			fmt.Printf("      ⚠️ No Channels Set for any session.\n")
+		}
+		owners := planOwners(plan, apiIdx)
+		PublishOTPEvent(record, owners)
+		EmitOTPWebhooks(record, owners)
+
 		sentCount := 0
+		for _, d := range plan {
+			messageBody := formatMessage(cFlag, service, apiIdx, rawTime, cleanCountry, maskedPhone, otpCode, flatMsg, d.Settings.CustomLink)
+
+			if !checkSchedule(d.Owner, d.Target, msgID, messageBody) {
+				continue
+			}
+
+			fmt.Printf("      📤 Sending (Forwarded Style) to: %s via %s ... ", d.Target, d.Session)
 
-		for jidStr, cli := range ActiveClients {
-			activeSessionCount++
-			
-			if cli.IsConnected() && cli.IsLoggedIn() {
-				settings := GetUserSettings(jidStr)
-				
-				fmt.Printf("   👤 Checking Session: %s | Channels: %d\n", jidStr, len(settings.Channels))
-
-				if len(settings.Channels) > 0 {
-					messageBody := formatMessage(cFlag, service, apiIdx, rawTime, cleanCountry, maskedPhone, otpCode, flatMsg, settings.CustomLink)
-					
-					for _, ch := range settings.Channels {
-						jid, _ := types.ParseJID(ch)
-						
-						fmt.Printf("      📤 Sending (Forwarded Style) to: %s ... ", ch)
-						
-						// 🔥 FORWARDED MESSAGE LOGIC HERE
-						msgParams := &waProto.Message{
-							ExtendedTextMessage: &waProto.ExtendedTextMessage{
-								Text: proto.String(strings.TrimSpace(messageBody)),
-								ContextInfo: &waProto.ContextInfo{
-									// 1. میسج کو "Forwarded" ٹیگ دینا
-									IsForwarded: proto.Bool(true),
-									ForwardingScore: proto.Uint32(5), // کوئی بھی نمبر دے دیں
-									
-									// 2. چینل کا ریفرنس (Promotion)
-									ForwardedNewsletterMessageInfo: &waProto.ForwardedNewsletterMessageInfo{
-										NewsletterJID:   proto.String(PromoChannelID),
-										NewsletterName:  proto.String(PromoChannelName),
-										ServerMessageID: proto.Int32(100), // ڈمی آئی ڈی
-										ContentType:     waProto.ForwardedNewsletterMessageInfo_UPDATE.Enum(),
-									},
-								},
-							},
-						}
-
-						_, err := cli.SendMessage(context.Background(), jid, msgParams)
-						
-						if err != nil {
-							fmt.Printf("❌ FAILED: %v\n", err)
-						} else {
-							fmt.Printf("✅ SUCCESS!\n")
-							sentCount++
-						}
-					}
-				} else {
-					fmt.Printf("      ⚠️ No Channels Set for this user.\n")
-				}
+			n := WhatsAppNotifier{Client: d.Client, Target: d.Target}
+			err := n.Notify(context.Background(), messageBody)
+			LogDelivery(msgID, d.Target, d.Owner, d.Session, err)
+			PublishDeliveryEvent(record, d.Target, d.Owner, d.Session, err)
+			if err != nil {
+				fmt.Printf("❌ FAILED: %v\n", err)
 			} else {
-				fmt.Printf("   🚫 Session %s Disconnected\n", jidStr)
+				fmt.Printf("✅ SUCCESS!\n")
+				sentCount++
 			}
 		}
-		ClientMutex.Unlock()
+		fmt.Printf("   📊 Delivered to %d/%d targets\n", sentCount, len(plan))
+
+		deliverWatches(record, cFlag, flatMsg)
+		deliverSinks(record, cFlag, owners)
 
 		MarkOTPSent(msgID)
 	}
 }
 
+// sendForwarded میسج کو "Forwarded" اسٹائل میں بھیجتا ہے (چینل پروموشن کے ساتھ)
+func sendForwarded(ctx context.Context, cli *whatsmeow.Client, target, messageBody string) error {
+	jid, err := types.ParseJID(target)
+	if err != nil {
+		return err
+	}
+
+	// 🔥 FORWARDED MESSAGE LOGIC HERE
+	msgParams := &waProto.Message{
+		ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			Text: proto.String(strings.TrimSpace(messageBody)),
+			ContextInfo: &waProto.ContextInfo{
+				// 1. میسج کو "Forwarded" ٹیگ دینا
+				IsForwarded:     proto.Bool(true),
+				ForwardingScore: proto.Uint32(5), // کوئی بھی نمبر دے دیں
+
+				// 2. چینل کا ریفرنس (Promotion)
+				ForwardedNewsletterMessageInfo: &waProto.ForwardedNewsletterMessageInfo{
+					NewsletterJID:   proto.String(PromoChannelID),
+					NewsletterName:  proto.String(PromoChannelName),
+					ServerMessageID: proto.Int32(100), // ڈمی آئی ڈی
+					ContentType:     waProto.ForwardedNewsletterMessageInfo_UPDATE.Enum(),
+				},
+			},
+		},
+	}
+
+	_, err = cli.SendMessage(ctx, jid, msgParams)
+	return err
+}
+
 func formatMessage(cFlag, service string, apiIdx int, rawTime, country, phone, otp, fullMsg, link string) string {
 	return fmt.Sprintf("✨ *%s | %s Message %d* ⚡\n\n"+
 		"> *Time:* %s\n"+
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 🧹 SENT HISTORY PRUNING
+// ---------------------------------------------------------
+
+var (
+	// liveFeedIDs ہر API کے آخری کامیاب poll میں موجود msg_ids
+	liveFeedIDs   = make(map[int]map[string]bool)
+	liveFeedMutex sync.Mutex
+
+	// prune job لکھتا ہے، /health پڑھتا ہے
+	pruneMutex sync.Mutex
+	lastPrune  time.Time
+	lastPruned int
+)
+
+// setLiveFeedIDs صرف کامیاب poll کے بعد، تاکہ API ڈاؤن ہو تو پرانا سیٹ محفوظ رہے
+func setLiveFeedIDs(apiIdx int, ids map[string]bool) {
+	liveFeedMutex.Lock()
+	liveFeedIDs[apiIdx] = ids
+	liveFeedMutex.Unlock()
+}
+
+func allLiveFeedIDs() map[string]bool {
+	liveFeedMutex.Lock()
+	defer liveFeedMutex.Unlock()
+	all := make(map[string]bool)
+	for _, ids := range liveFeedIDs {
+		for id := range ids {
+			all[id] = true
+		}
+	}
+	return all
+}
+
+// StartPruneJob ہر گھنٹے retention سے پرانی sent_history ہٹاتا ہے
+func StartPruneJob() {
+	fmt.Printf("🧹 Prune Job Started (retention %s)\n", SentHistoryRetention)
+	for {
+		time.Sleep(1 * time.Hour)
+		restoreGate.RLock()
+		n, err := PruneSentHistory(time.Now().Add(-SentHistoryRetention), allLiveFeedIDs())
+		restoreGate.RUnlock()
+		if err != nil {
+			fmt.Printf("❌ [PRUNE] sent_history: %v\n", err)
+			continue
+		}
+		pruneMutex.Lock()
+		lastPrune, lastPruned = time.Now(), n
+		pruneMutex.Unlock()
+		if n > 0 {
+			fmt.Printf("🧹 [PRUNE] Removed %d old sent_history rows\n", n)
+		}
+	}
+}
+
+// ---------------------------------------------------------
+// 🩺 HEALTH
+// ---------------------------------------------------------
+
+// GET /health
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	_, admin, _ := authenticateRequest(r)
+
+	ClientMutex.Lock()
+	total, online := len(ActiveClients), 0
+	for _, c := range ActiveClients {
+		if c.IsConnected() && c.IsLoggedIn() {
+			online++
+		}
+	}
+	ClientMutex.Unlock()
+
+	pruneMutex.Lock()
+	prune := map[string]interface{}{
+		"retention_hours": SentHistoryRetention.Hours(),
+		"last_run":        nil,
+		"last_removed":    lastPruned,
+	}
+	if !lastPrune.IsZero() {
+		prune["last_run"] = lastPrune.Unix()
+	}
+	pruneMutex.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"sessions": map[string]int{
+			"total":  total,
+			"online": online,
+		},
+		"tables":    TableSizes(),
+		"prune":     prune,
+		"lid_cache": lidCacheStats(admin),
+	})
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 🔎 OTP HISTORY SEARCH
+// ---------------------------------------------------------
+
+// otpSearchVector PostgreSQL میں full-text کے لیے (index اور query دونوں میں ایک جیسا)
+const otpSearchVector = `to_tsvector('simple', coalesce(phone, '') || ' ' || coalesce(service, '') || ' ' || coalesce(country, '') || ' ' || coalesce(code, '') || ' ' || coalesce(body, ''))`
+
+// OTPFilter تلاش/ایکسپورٹ کے فلٹرز (خالی فیلڈ = کوئی فلٹر نہیں)
+type OTPFilter struct {
+	Text    string
+	Phone   string // مکمل نمبر یا آخری ہندسے
+	Country string
+	Service string
+	Source  int
+	From    time.Time
+	To      time.Time
+
+	// Phones غیر admin صارفین کے لیے: صرف یہی نمبرز (nil = سب)
+	Phones []string
+
+	Limit  int
+	Offset int
+}
+
+// whereClause فلٹر سے WHERE اور اس کے args ($1, $2...)
+func (f OTPFilter) whereClause() (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.Text != "" {
+		if dbDialect == "postgres" {
+			conds = append(conds, otpSearchVector+" @@ plainto_tsquery('simple', "+arg(f.Text)+")")
+		} else {
+			conds = append(conds, "rowid IN (SELECT docid FROM otp_history_fts WHERE otp_history_fts MATCH "+arg(ftsQuery(f.Text))+")")
+		}
+	}
+	if f.Phone != "" {
+		conds = append(conds, "(phone = "+arg(f.Phone)+" OR phone LIKE "+arg("%"+f.Phone)+")")
+	}
+	if f.Country != "" {
+		conds = append(conds, "LOWER(country) = "+arg(strings.ToLower(f.Country)))
+	}
+	if f.Service != "" {
+		conds = append(conds, "LOWER(service) LIKE "+arg("%"+strings.ToLower(f.Service)+"%"))
+	}
+	if f.Source > 0 {
+		conds = append(conds, "source = "+arg(f.Source))
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "created_at >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "created_at < "+arg(f.To))
+	}
+	if f.Phones != nil {
+		if len(f.Phones) == 0 {
+			conds = append(conds, "1 = 0")
+		} else {
+			ph := []string{}
+			for _, p := range f.Phones {
+				ph = append(ph, arg(p))
+			}
+			conds = append(conds, "phone IN ("+strings.Join(ph, ", ")+")")
+		}
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// ftsQuery صارف کے متن کو محفوظ FTS4 query میں (ہر لفظ الگ phrase، سب AND)
+func ftsQuery(text string) string {
+	terms := []string{}
+	for _, w := range strings.Fields(text) {
+		w = strings.ReplaceAll(w, `"`, "")
+		if w != "" {
+			terms = append(terms, `"`+w+`"`)
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchOTPRecords نتائج (تازہ ترین پہلے) اور کل تعداد
+func SearchOTPRecords(f OTPFilter) ([]OTPRecord, int) {
+	where, args := f.whereClause()
+
+	total := 0
+	db.QueryRow("SELECT COUNT(*) FROM otp_history"+where, args...).Scan(&total)
+
+	list := []OTPRecord{}
+	query := "SELECT msg_id, source, country, phone, service, code, body, raw_time, created_at FROM otp_history" + where + " ORDER BY created_at DESC"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", f.Limit, f.Offset)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		fmt.Printf("❌ [SEARCH] %v\n", err)
+		return list, total
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r OTPRecord
+		if rows.Scan(&r.MsgID, &r.Source, &r.Country, &r.Phone, &r.Service, &r.Code, &r.Body, &r.RawTime, &r.CreatedAt) == nil {
+			list = append(list, r)
+		}
+	}
+	return list, total
+}
+
+// restrictForUser غیر admin صرف اپنے watched نمبرز دیکھ سکتا ہے
+// admin بھیجنے والے کے حساب سے (سیشن کا نمبر admin ہو تو اس کے viewers admin نہیں بنتے)
+func restrictForUser(f *OTPFilter, user string, admin bool) {
+	if admin {
+		return
+	}
+	f.Phones = []string{}
+	for _, w := range GetWatches(user) {
+		f.Phones = append(f.Phones, w.Number)
+	}
+}
+
+// parseSearchArgs ".search whatsapp country:pakistan service:tg source:neon phone:1234 from:2025-01-01 page:2"
+func parseSearchArgs(args []string) (OTPFilter, int) {
+	f := OTPFilter{}
+	page := 1
+	text := []string{}
+	for _, a := range args {
+		key, val, found := strings.Cut(a, ":")
+		if !found || val == "" {
+			text = append(text, a)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "country":
+			f.Country = val
+		case "service":
+			f.Service = val
+		case "source":
+			f.Source = sourceIndex(val)
+		case "phone", "number":
+			f.Phone = normalizeNumber(val)
+		case "from":
+			f.From = parseTimeParam(val)
+		case "to":
+			f.To = parseTimeParam(val)
+		case "page":
+			if p, err := strconv.Atoi(val); err == nil && p > 0 {
+				page = p
+			}
+		default:
+			text = append(text, a)
+		}
+	}
+	f.Text = strings.Join(text, " ")
+	return f, page
+}
+
+// writeOTPCSV ریکارڈز کو CSV میں (search document اور export دونوں کے لیے)
+func writeOTPCSV(records []OTPRecord) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"created_at", "raw_time", "source", "country", "phone", "service", "code", "body"})
+	for _, r := range records {
+		w.Write([]string{
+			r.CreatedAt.UTC().Format(time.RFC3339),
+			r.RawTime,
+			sourceName(r.Source),
+			r.Country,
+			r.Phone,
+			r.Service,
+			r.Code,
+			r.Body,
+		})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// parseTimeParam "2025-01-31" یا RFC3339
+func parseTimeParam(v string) time.Time {
+	if v == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// otpFilterFromQuery HTTP query params سے فلٹر
+func otpFilterFromQuery(r *http.Request) OTPFilter {
+	q := r.URL.Query()
+	f := OTPFilter{
+		Text:    q.Get("q"),
+		Phone:   normalizeNumber(q.Get("phone")),
+		Country: q.Get("country"),
+		Service: q.Get("service"),
+		From:    parseTimeParam(q.Get("from")),
+		To:      parseTimeParam(q.Get("to")),
+	}
+	if src := q.Get("source"); src != "" {
+		f.Source = sourceIndex(src)
+	}
+	return f
+}
+
+// GET /api/search?q=&phone=&country=&service=&source=&from=&to=&page=1&per_page=50
+func handleSearchAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	user, admin, ok := authenticateRequest(r)
+	if !ok {
+		http.Error(w, `{"error":"Unauthorized"}`, 401)
+		return
+	}
+
+	f := otpFilterFromQuery(r)
+	restrictForUser(&f, user, admin)
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 || perPage > 500 {
+		perPage = 50
+	}
+	f.Limit, f.Offset = perPage, (page-1)*perPage
+
+	records, total := SearchOTPRecords(f)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"records":  records,
+	})
+}
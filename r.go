@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// ---------------------------------------------------------
+// 🧭 DELIVERY PLANNING (Target-Centric)
+// ---------------------------------------------------------
+
+// Delivery ایک ٹارگٹ پر ایک OTP بھیجنے کا پلان
+// Owner جس کی settings (template/link) استعمال ہوں گی
+// Session وہ سیشن ہے جو اصل میں بھیجے گا (Owner خود یا اس کا Backup)
+type Delivery struct {
+	Target   string
+	Owner    string
+	Session  string
+	Client   *whatsmeow.Client
+	Settings UserSettings
+}
+
+// planOwners وہ تمام مالک جن کے چینلز تک یہ OTP جا رہا ہے
+// (ٹارگٹ کسی اور سیشن نے claim کیا ہو تب بھی)؛ webhooks/sinks صرف انہی کو
+func planOwners(plan []Delivery, apiIdx int) map[string]bool {
+	owners := map[string]bool{}
+	for _, d := range plan {
+		owners[d.Owner] = true
+		for _, o := range GetChannelOwners(d.Target) {
+			if !owners[o] && GetSourceSubs(o).Allows(d.Target, apiIdx) {
+				owners[o] = true
+			}
+		}
+	}
+	return owners
+}
+
+// planDeliveries ہر الگ ٹارگٹ کے لیے صرف ایک سیشن چنتا ہے
+// اگر دو سیشنز ایک ہی گروپ/چینل پر ایکٹو ہوں تو OTP دو بار نہیں جائے گا
+// Policy:
+//  1. سیشنز JID کے حساب سے sort ہوتے ہیں، پہلا connected مالک جیتتا ہے
+//  2. جن ٹارگٹس کا کوئی مالک connected نہیں، وہ مالک کے پہلے healthy backup سے جاتے ہیں
+//  3. جو مالک/چینل اس source (apiIdx) کو subscribe نہیں، وہ ٹارگٹ claim نہیں کرتا
+func planDeliveries(apiIdx int) []Delivery {
+	ClientMutex.Lock()
+	sessions := make([]string, 0, len(ActiveClients))
+	clients := make(map[string]*whatsmeow.Client, len(ActiveClients))
+	for jidStr, cli := range ActiveClients {
+		sessions = append(sessions, jidStr)
+		clients[jidStr] = cli
+	}
+	ClientMutex.Unlock()
+
+	sort.Strings(sessions)
+
+	healthy := func(jidStr string) bool {
+		cli, ok := clients[jidStr]
+		return ok && cli.IsConnected() && cli.IsLoggedIn()
+	}
+
+	plan := []Delivery{}
+	owner := make(map[string]string) // target -> session
+	down := []string{}
+
+	// Pass 1: Direct delivery
+	for _, jidStr := range sessions {
+		if !healthy(jidStr) {
+			fmt.Printf("   🚫 Session %s Disconnected\n", jidStr)
+			down = append(down, jidStr)
+			continue
+		}
+
+		settings := GetUserSettings(jidStr)
+		subs := GetSourceSubs(jidStr)
+		fmt.Printf("   👤 Checking Session: %s | Channels: %d\n", jidStr, len(settings.Channels))
+
+		for _, ch := range settings.Channels {
+			if !subs.Allows(ch, apiIdx) {
+				continue
+			}
+			if prev, taken := owner[ch]; taken {
+				fmt.Printf("      ↪️ %s already served by %s, skipping duplicate\n", ch, prev)
+				continue
+			}
+			owner[ch] = jidStr
+			plan = append(plan, Delivery{
+				Target:   ch,
+				Owner:    jidStr,
+				Session:  jidStr,
+				Client:   clients[jidStr],
+				Settings: settings,
+			})
+		}
+	}
+
+	// Pass 2: Failover for sessions that are down
+	for _, jidStr := range down {
+		settings := GetUserSettings(jidStr)
+		if len(settings.Channels) == 0 {
+			continue
+		}
+
+		backup := ""
+		for _, b := range GetBackups(jidStr) {
+			if healthy(b) {
+				backup = b
+				break
+			}
+		}
+		if backup == "" {
+			fmt.Printf("   ⚠️ No healthy backup for %s, %d channels skipped\n", jidStr, len(settings.Channels))
+			continue
+		}
+
+		fmt.Printf("   🔁 Failover: %s → %s\n", jidStr, backup)
+		subs := GetSourceSubs(jidStr)
+		for _, ch := range settings.Channels {
+			if !subs.Allows(ch, apiIdx) {
+				continue
+			}
+			if _, taken := owner[ch]; taken {
+				continue
+			}
+			owner[ch] = backup
+			plan = append(plan, Delivery{
+				Target:   ch,
+				Owner:    jidStr,
+				Session:  backup,
+				Client:   clients[backup],
+				Settings: settings,
+			})
+		}
+	}
+	return plan
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// ---------------------------------------------------------
+// 📡 LIVE STREAM (SSE + WebSocket)
+// ---------------------------------------------------------
+
+// StreamEvent وہ JSON جو ہر سبسکرائبر کو push ہوتا ہے
+type StreamEvent struct {
+	Type string      `json:"type"` // otp | delivery
+	Time int64       `json:"time"`
+	Data interface{} `json:"data"`
+
+	// فلٹرنگ کے لیے (JSON میں نہیں جاتے)
+	owner   string
+	viewers map[string]bool // otp: اس نمبر کے watchers (canViewNumber)، مکمل نمبر
+	owners  map[string]bool // otp: جن کے ٹارگٹس کو یہ OTP پلان ہوا، masked نمبر
+	masked  interface{}     // owners کے لیے Data
+	country string
+	service string
+	source  int
+}
+
+type streamFilter struct {
+	countries map[string]bool
+	services  []string
+	sources   map[int]bool
+}
+
+type streamSub struct {
+	ch     chan StreamEvent
+	user   string
+	admin  bool
+	subs   SourceSubs
+	filter streamFilter
+}
+
+var (
+	streamSubs  = make(map[*streamSub]bool)
+	streamMutex sync.Mutex
+)
+
+// parseStreamFilter ?country=pakistan,india&service=whatsapp&source=neon,2
+func parseStreamFilter(r *http.Request) streamFilter {
+	q := r.URL.Query()
+	f := streamFilter{countries: map[string]bool{}, sources: map[int]bool{}}
+	for _, c := range splitList(q.Get("country")) {
+		f.countries[strings.ToLower(c)] = true
+	}
+	for _, s := range splitList(q.Get("service")) {
+		f.services = append(f.services, strings.ToLower(s))
+	}
+	for _, s := range splitList(q.Get("source")) {
+		if idx := sourceIndex(s); idx > 0 {
+			f.sources[idx] = true
+		}
+	}
+	return f
+}
+
+func (s *streamSub) wants(evt StreamEvent) bool {
+	// Delivery نتائج صرف اپنے (یا admin کو سب)
+	if evt.Type == "delivery" && !s.admin && evt.owner != s.user {
+		return false
+	}
+	// OTP: admin/watchers کو مکمل، اپنے ٹارگٹس والے owners کو masked، باقی کسی کو نہیں
+	if evt.Type == "otp" && !s.admin && !evt.viewers[s.user] && !evt.owners[s.user] {
+		return false
+	}
+	if !s.admin && !s.subs.Allows("", evt.source) {
+		return false
+	}
+	if len(s.filter.countries) > 0 && !s.filter.countries[strings.ToLower(evt.country)] {
+		return false
+	}
+	if len(s.filter.sources) > 0 && !s.filter.sources[evt.source] {
+		return false
+	}
+	if len(s.filter.services) > 0 {
+		match := false
+		for _, svc := range s.filter.services {
+			if strings.Contains(strings.ToLower(evt.service), svc) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+func addStreamSub(r *http.Request) (*streamSub, bool) {
+	user, admin, ok := authenticateStream(r)
+	if !ok {
+		return nil, false
+	}
+	sub := &streamSub{
+		ch:     make(chan StreamEvent, 64),
+		user:   user,
+		admin:  admin,
+		subs:   GetSourceSubs(user),
+		filter: parseStreamFilter(r),
+	}
+	streamMutex.Lock()
+	streamSubs[sub] = true
+	streamMutex.Unlock()
+	return sub, true
+}
+
+func removeStreamSub(sub *streamSub) {
+	streamMutex.Lock()
+	delete(streamSubs, sub)
+	streamMutex.Unlock()
+}
+
+// publishStream کبھی block نہیں کرتا، سست کلائنٹ کے ایونٹس drop ہو جاتے ہیں
+func publishStream(evt StreamEvent) {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+	for sub := range streamSubs {
+		if !sub.wants(evt) {
+			continue
+		}
+		out := evt
+		if evt.Type == "otp" && !sub.admin && !evt.viewers[sub.user] {
+			out.Data = evt.masked
+		}
+		select {
+		case sub.ch <- out:
+		default:
+		}
+	}
+}
+
+// PublishOTPEvent owners: planOwners، یعنی جن کے چینلز پر یہ OTP جا رہا ہے
+func PublishOTPEvent(rec OTPRecord, owners map[string]bool) {
+	viewers := map[string]bool{}
+	for _, w := range GetWatchersForNumber(rec.Phone) {
+		viewers[w.Watcher] = true
+	}
+	publishStream(StreamEvent{
+		Type:    "otp",
+		Time:    time.Now().Unix(),
+		Data:    otpEventData(rec, rec.Phone),
+		masked:  otpEventData(rec, maskPhoneNumber(rec.Phone)),
+		viewers: viewers,
+		owners:  owners,
+		country: rec.Country,
+		service: rec.Service,
+		source:  rec.Source,
+	})
+}
+
+func otpEventData(rec OTPRecord, phone string) map[string]interface{} {
+	return map[string]interface{}{
+		"msg_id":      rec.MsgID,
+		"source":      rec.Source,
+		"source_name": sourceName(rec.Source),
+		"country":     rec.Country,
+		"phone":       phone,
+		"service":     rec.Service,
+		"code":        rec.Code,
+		"body":        rec.Body,
+		"raw_time":    rec.RawTime,
+	}
+}
+
+func PublishDeliveryEvent(rec OTPRecord, target, owner, session string, sendErr error) {
+	status, errText := "sent", ""
+	if sendErr != nil {
+		status, errText = "failed", sendErr.Error()
+	}
+	publishStream(StreamEvent{
+		Type: "delivery",
+		Time: time.Now().Unix(),
+		Data: map[string]interface{}{
+			"msg_id":  rec.MsgID,
+			"target":  target,
+			"owner":   owner,
+			"session": session,
+			"status":  status,
+			"error":   errText,
+		},
+		owner:   owner,
+		country: rec.Country,
+		service: rec.Service,
+		source:  rec.Source,
+	})
+}
+
+// GET /api/stream?token=...&country=...&service=...&source=...
+func handleStreamSSE(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error":"Streaming unsupported"}`, 500)
+		return
+	}
+	sub, ok := addStreamSub(r)
+	if !ok {
+		http.Error(w, `{"error":"Unauthorized"}`, 401)
+		return
+	}
+	defer removeStreamSub(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprintf(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(25 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			flusher.Flush()
+		case evt := <-sub.ch:
+			data, _ := json.Marshal(evt)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// GET /api/ws?token=...&country=...&service=...&source=... (WebSocket)
+func handleStreamWS(w http.ResponseWriter, r *http.Request) {
+	sub, ok := addStreamSub(r)
+	if !ok {
+		http.Error(w, `{"error":"Unauthorized"}`, 401)
+		return
+	}
+	defer removeStreamSub(sub)
+
+	// Origin check فعال: دوسری سائٹ کا صفحہ صارف کے token والا socket نہ کھول سکے
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: WSAllowedOrigins})
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+
+	// کلائنٹ سے کچھ پڑھنا نہیں، صرف close/ping ہینڈل کرنے کے لیے
+	ctx := conn.CloseRead(r.Context())
+
+	heartbeat := time.NewTicker(25 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			err := conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return
+			}
+		case evt := <-sub.ch:
+			writeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			err := wsjson.Write(writeCtx, conn, evt)
+			cancel()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryTokenOnlyForStreams(t *testing.T) {
+	old := AdminToken
+	AdminToken = "admin-secret"
+	defer func() { AdminToken = old }()
+
+	r := httptest.NewRequest("GET", "/api/otp?number=923001234567&token=admin-secret", nil)
+	if _, _, ok := authenticateRequest(r); ok {
+		t.Fatal("query-string token accepted on a normal API route")
+	}
+	if _, admin, ok := authenticateStream(r); !ok || !admin {
+		t.Fatal("query-string token rejected on the stream handshake")
+	}
+	r.Header.Set("Authorization", "Bearer admin-secret")
+	if _, _, ok := authenticateRequest(r); !ok {
+		t.Fatal("bearer token rejected")
+	}
+}
+
+func TestOTPEventMaskedForOwners(t *testing.T) {
+	testStores(t)
+
+	subscribe := func(user string) *streamSub {
+		sub := &streamSub{ch: make(chan StreamEvent, 4), user: user, subs: SourceSubs{}}
+		streamMutex.Lock()
+		streamSubs[sub] = true
+		streamMutex.Unlock()
+		t.Cleanup(func() { removeStreamSub(sub) })
+		return sub
+	}
+	owner, stranger := subscribe("923001234567"), subscribe("923007654321")
+
+	rec := OTPRecord{MsgID: "923009876543_1", Source: 1, Phone: "923009876543", Code: "123456"}
+	PublishOTPEvent(rec, map[string]bool{"923001234567": true})
+
+	select {
+	case evt := <-owner.ch:
+		data := evt.Data.(map[string]interface{})
+		if data["phone"] != maskPhoneNumber(rec.Phone) || data["code"] != "123456" {
+			t.Fatalf("owner got %v, want masked phone with code", data)
+		}
+	default:
+		t.Fatal("owner got no OTP event for its own target")
+	}
+	select {
+	case evt := <-stranger.ch:
+		t.Fatalf("unrelated token holder got %v", evt.Data)
+	default:
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Alpine امیج میں zoneinfo نہیں ہوتا
+
+	"go.mau.fi/whatsmeow"
+)
+
+// ---------------------------------------------------------
+// ⏰ QUIET HOURS / DELIVERY SCHEDULES
+// ---------------------------------------------------------
+
+const digestChunkSize = 10
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// IsOpen کیا اس وقت چینل کی ونڈو کھلی ہے (چینل کے اپنے ٹائم زون میں)
+// StartHour > EndHour کا مطلب رات بھر کی ونڈو (مثلاً 22-06)
+func (s Schedule) IsOpen(now time.Time) bool {
+	loc, err := time.LoadLocation(s.TZ)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+
+	day := local.Weekday()
+	hour := local.Hour()
+	if s.StartHour > s.EndHour && hour < s.EndHour {
+		// رات والی ونڈو کا صبح والا حصہ پچھلے دن کا ہے
+		day = (day + 6) % 7
+	}
+	if s.Days != "" && !strings.Contains(s.Days, weekdayNames[day]) {
+		return false
+	}
+
+	switch {
+	case s.StartHour == s.EndHour:
+		return true // پورا دن
+	case s.StartHour < s.EndHour:
+		return hour >= s.StartHour && hour < s.EndHour
+	default:
+		return hour >= s.StartHour || hour < s.EndHour
+	}
+}
+
+func (s Schedule) String() string {
+	days := s.Days
+	if days == "" {
+		days = "daily"
+	}
+	return fmt.Sprintf("%s %02d-%02d %s (%s)", days, s.StartHour, s.EndHour, s.TZ, s.Mode)
+}
+
+// parseDays "mon-fri", "sat,sun", "daily" → "mon,tue,wed,thu,fri"
+func parseDays(raw string) (string, error) {
+	raw = strings.ToLower(raw)
+	if raw == "daily" || raw == "all" || raw == "*" {
+		return "", nil
+	}
+	index := func(name string) int {
+		for i, d := range weekdayNames {
+			if strings.HasPrefix(name, d) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	set := make([]bool, 7)
+	for _, part := range strings.Split(raw, ",") {
+		if bounds := strings.SplitN(part, "-", 2); len(bounds) == 2 {
+			from, to := index(bounds[0]), index(bounds[1])
+			if from < 0 || to < 0 {
+				return "", fmt.Errorf("Invalid days: %s", part)
+			}
+			for i := from; ; i = (i + 1) % 7 {
+				set[i] = true
+				if i == to {
+					break
+				}
+			}
+		} else {
+			i := index(part)
+			if i < 0 {
+				return "", fmt.Errorf("Invalid day: %s", part)
+			}
+			set[i] = true
+		}
+	}
+
+	days := []string{}
+	for i, on := range set {
+		if on {
+			days = append(days, weekdayNames[i])
+		}
+	}
+	return strings.Join(days, ","), nil
+}
+
+// parseHours "09-18" → 9, 18
+func parseHours(raw string) (int, int, error) {
+	parts := strings.SplitN(raw, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid hours: %s (use 09-18)", raw)
+	}
+	start, err1 := strconv.Atoi(parts[0])
+	end, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || start < 0 || start > 23 || end < 0 || end > 24 {
+		return 0, 0, fmt.Errorf("Invalid hours: %s (use 09-18)", raw)
+	}
+	return start, end % 24, nil
+}
+
+// checkSchedule ڈیلیوری سے پہلے: true = ابھی بھیجیں
+// ونڈو بند ہو تو "hold" موڈ میں OTP digest کے لیے محفوظ ہو جاتا ہے
+func checkSchedule(owner, channel, msgID, body string) bool {
+	sched, ok := GetSchedule(owner, channel)
+	if !ok || sched.IsOpen(time.Now()) {
+		return true
+	}
+	if sched.Mode == "hold" {
+		HoldOTP(owner, channel, msgID, body)
+		fmt.Printf("      ⏸️ %s outside schedule, held for digest\n", channel)
+	} else {
+		fmt.Printf("      🌙 %s outside schedule, dropped\n", channel)
+	}
+	return false
+}
+
+// StartDigestJob ہر منٹ چیک کرتا ہے کہ کس چینل کی ونڈو کھل گئی اور held OTPs بھیجتا ہے
+func StartDigestJob() {
+	for {
+		time.Sleep(1 * time.Minute)
+		flushDigests()
+	}
+}
+
+func flushDigests() {
+	restoreGate.RLock()
+	defer restoreGate.RUnlock()
+	for _, sched := range GetSchedules("") {
+		if sched.Mode != "hold" || !sched.IsOpen(time.Now()) {
+			continue
+		}
+		held := GetHeldOTPs(sched.Owner, sched.Channel)
+		if len(held) == 0 {
+			continue
+		}
+		sendDigest(sched, held)
+	}
+}
+
+func sendDigest(sched Schedule, held []HeldOTP) {
+	session, cli := pickSender(sched.Owner)
+	if cli == nil {
+		held = dropStaleHeld(sched, held, "", fmt.Errorf("No healthy session"))
+		fmt.Printf("⚠️ [DIGEST] No healthy session for %s, keeping %d held\n", sched.Owner, len(held))
+		return
+	}
+
+	for start := 0; start < len(held); start += digestChunkSize {
+		end := start + digestChunkSize
+		if end > len(held) {
+			end = len(held)
+		}
+		chunk := held[start:end]
+
+		body := fmt.Sprintf("🗂️ *OTP Digest* (%d-%d of %d held)\n", start+1, end, len(held))
+		ids := []int64{}
+		for _, h := range chunk {
+			body += "\n━━━━━━━━━━━━━━\n" + strings.TrimSpace(h.Body) + "\n"
+			ids = append(ids, h.ID)
+		}
+
+		n := WhatsAppNotifier{Client: cli, Target: sched.Channel}
+		err := n.Notify(context.Background(), body)
+		if err != nil {
+			fmt.Printf("❌ [DIGEST] %s via %s FAILED: %v\n", sched.Channel, session, err)
+			// بوٹ گروپ سے نکال دیا گیا یا چینل ڈیلیٹ: حد کے بعد چھوڑ دیں، ہر منٹ ہمیشہ retry نہیں
+			BumpHeldAttempts(ids)
+			for i := start; i < end; i++ {
+				held[i].Attempts++
+			}
+			dropStaleHeld(sched, held[start:], session, err)
+			return
+		}
+		for _, h := range chunk {
+			LogDelivery(h.MsgID, sched.Channel, sched.Owner, session, nil)
+		}
+		DeleteHeldOTPs(ids)
+		fmt.Printf("🗂️ [DIGEST] Sent %d held OTPs to %s via %s\n", len(chunk), sched.Channel, session)
+	}
+}
+
+// dropStaleHeld DigestMaxAttempts یا HeldOTPMaxAge سے آگے والے held OTPs ہٹا کر failed لاگ کرتا ہے؛ باقی واپس
+func dropStaleHeld(sched Schedule, held []HeldOTP, session string, cause error) []HeldOTP {
+	keep, drop := []HeldOTP{}, []int64{}
+	for _, h := range held {
+		if h.Attempts < DigestMaxAttempts && time.Since(h.CreatedAt) < HeldOTPMaxAge {
+			keep = append(keep, h)
+			continue
+		}
+		LogDelivery(h.MsgID, sched.Channel, sched.Owner, session,
+			fmt.Errorf("Held OTP dropped after %d attempts (%s old): %v", h.Attempts, formatUptime(time.Since(h.CreatedAt)), cause))
+		drop = append(drop, h.ID)
+	}
+	if len(drop) > 0 {
+		DeleteHeldOTPs(drop)
+		fmt.Printf("🗑️ [DIGEST] Dropped %d held OTPs for %s: %v\n", len(drop), sched.Channel, cause)
+	}
+	return keep
+}
+
+// pickSender مالک کا اپنا سیشن، ورنہ پہلا healthy backup
+func pickSender(owner string) (string, *whatsmeow.Client) {
+	candidates := append([]string{owner}, GetBackups(owner)...)
+	ClientMutex.Lock()
+	defer ClientMutex.Unlock()
+	for _, jidStr := range candidates {
+		if c, ok := ActiveClients[jidStr]; ok && c.IsConnected() && c.IsLoggedIn() {
+			return jidStr, c
+		}
+	}
+	return "", nil
+}
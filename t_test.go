@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDropStaleHeld(t *testing.T) {
+	testStores(t)
+	sched := Schedule{Owner: "923001234567", Channel: "120363001@newsletter", Mode: "hold"}
+
+	HoldOTP(sched.Owner, sched.Channel, "fresh", "a")
+	HoldOTP(sched.Owner, sched.Channel, "retried", "b")
+	HoldOTP(sched.Owner, sched.Channel, "old", "c")
+	db.Exec("UPDATE held_otps SET attempts = $1 WHERE msg_id = 'retried'", DigestMaxAttempts-1)
+	db.Exec("UPDATE held_otps SET created_at = $1 WHERE msg_id = 'old'", time.Now().Add(-HeldOTPMaxAge-time.Hour))
+
+	held := GetHeldOTPs(sched.Owner, sched.Channel)
+	ids := []int64{}
+	for i := range held {
+		ids = append(ids, held[i].ID)
+		held[i].Attempts++
+	}
+	BumpHeldAttempts(ids)
+
+	keep := dropStaleHeld(sched, held, "923001234567", fmt.Errorf("not a group admin"))
+	if len(keep) != 1 || keep[0].MsgID != "fresh" {
+		t.Fatalf("kept %+v, want only fresh", keep)
+	}
+	left := GetHeldOTPs(sched.Owner, sched.Channel)
+	if len(left) != 1 || left[0].MsgID != "fresh" || left[0].Attempts != 1 {
+		t.Fatalf("held_otps = %+v", left)
+	}
+	if n := CountDeliveries(sched.Owner, "failed", time.Now().Add(-time.Minute)); n != 2 {
+		t.Fatalf("failed deliveries = %d, want one per dropped OTP", n)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 )
 
 func extractOTP(msg string) string {
@@ -18,6 +19,18 @@ func maskPhoneNumber(phone string) string {
 	return fmt.Sprintf("%s•••%s", phone[:3], phone[len(phone)-4:])
 }
 
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func cleanCountryName(name string) string {
 	if name == "" {
 		return "Unknown"
@@ -28,3 +41,36 @@ func cleanCountryName(name string) string {
 	}
 	return "Unknown"
 }
+
+// parseInviteLink link سے invite code نکالتا ہے
+// kind: "group" (chat.whatsapp.com) یا "channel" (whatsapp.com/channel)
+func parseInviteLink(link string) (kind, code string) {
+	l := strings.TrimSpace(link)
+	l = strings.TrimPrefix(l, "https://")
+	l = strings.TrimPrefix(l, "http://")
+	l = strings.TrimPrefix(l, "www.")
+	l = strings.Split(strings.Split(l, "?")[0], "#")[0]
+	l = strings.TrimSuffix(l, "/")
+
+	if strings.HasPrefix(l, "chat.whatsapp.com/") {
+		return "group", strings.TrimPrefix(l, "chat.whatsapp.com/")
+	}
+	if strings.HasPrefix(l, "whatsapp.com/channel/") {
+		return "channel", strings.TrimPrefix(l, "whatsapp.com/channel/")
+	}
+	return "", ""
+}
+
+// formatAge "45s ago", "12m ago", "3h ago", "2d ago"
+func formatAge(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
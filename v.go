@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ---------------------------------------------------------
+// 🩺 SESSION & PIPELINE STATUS (.status)
+// ---------------------------------------------------------
+
+var startedAt = time.Now()
+
+// sourceState ہر upstream API کا آخری poll
+type sourceState struct {
+	LastPoll time.Time
+	LastOK   time.Time
+	LastErr  string
+	Rows     int
+	Failures int // مسلسل ناکامیاں
+}
+
+var (
+	sourceStates = make(map[int]sourceState)
+	connectedAt  = make(map[string]time.Time) // session -> آخری Connected
+	statusMutex  sync.Mutex
+)
+
+// recordSourcePoll processAPI ہر poll کے بعد
+func recordSourcePoll(apiIdx, rows int, err error) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	st := sourceStates[apiIdx]
+	st.LastPoll = time.Now()
+	if err != nil {
+		st.LastErr = pollErrorSummary(err)
+		st.Failures++
+	} else {
+		st.LastOK, st.LastErr, st.Rows, st.Failures = st.LastPoll, "", rows, 0
+	}
+	sourceStates[apiIdx] = st
+}
+
+// pollErrorSummary .status کے لیے: http کی غلطی میں upstream URL (اور اس کا token) ہوتا ہے،
+// اس لیے صرف قسم؛ پوری غلطی processAPI کے log میں
+func pollErrorSummary(err error) string {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		if uerr.Timeout() {
+			return "Timeout"
+		}
+		return "Connection failed"
+	}
+	return err.Error()
+}
+
+// trackSessionState EventHandler سے: uptime کے لیے connect/disconnect کا وقت
+func trackSessionState(session string, evt interface{}) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	switch evt.(type) {
+	case *events.Connected:
+		connectedAt[session] = time.Now()
+	case *events.Disconnected, *events.LoggedOut:
+		delete(connectedAt, session)
+	}
+}
+
+func cmdStatus(c *CommandContext) {
+	cli := c.Cli
+	msg := fmt.Sprintf("🩺 *Status* `%s`\n", c.Session)
+
+	statusMutex.Lock()
+	since, up := connectedAt[c.Session]
+	sources := make(map[int]sourceState, len(sourceStates))
+	for idx, st := range sourceStates {
+		sources[idx] = st
+	}
+	statusMutex.Unlock()
+
+	// --- Session ---
+	switch {
+	case cli.IsConnected() && cli.IsLoggedIn():
+		msg += "🔌 *Connection:* 🟢 Online"
+	case cli.IsLoggedIn():
+		msg += "🔌 *Connection:* 🟡 Reconnecting"
+	default:
+		msg += "🔌 *Connection:* 🔴 Logged out"
+	}
+	if up {
+		msg += " (connected " + formatAge(since) + ")"
+	}
+	msg += "\n⏱️ *Bot Uptime:* " + formatUptime(time.Since(startedAt)) + "\n"
+
+	store := cli.Store
+	msg += fmt.Sprintf("📱 *Device:* `%s`", store.ID.String())
+	if store.Platform != "" {
+		msg += " | " + store.Platform
+	}
+	if store.PushName != "" {
+		msg += " | " + store.PushName
+	}
+	if store.BusinessName != "" {
+		msg += " | 🏢 " + store.BusinessName
+	}
+	if !store.LID.IsEmpty() {
+		msg += fmt.Sprintf("\n🆔 *LID:* `%s`", store.LID.User)
+	}
+
+	// --- Pipeline ---
+	msg += fmt.Sprintf("\n📡 *Channels:* %d\n", len(GetUserSettings(c.Session).Channels))
+	if last, ok := LastDelivery(c.Session); ok {
+		msg += fmt.Sprintf("📤 *Last OTP:* %s → `%s`", formatAge(last.CreatedAt), last.Target)
+		if last.Service != "" {
+			msg += fmt.Sprintf(" (%s %s)", last.Service, maskPhoneNumber(last.Phone))
+		}
+		msg += "\n"
+	} else {
+		msg += "📤 *Last OTP:* none delivered yet\n"
+	}
+	hourAgo := time.Now().Add(-time.Hour)
+	failed := CountDeliveries(c.Session, "failed", hourAgo)
+	msg += fmt.Sprintf("❌ *Failed (1h):* %d of %d\n", failed, failed+CountDeliveries(c.Session, "sent", hourAgo))
+
+	// --- Upstream Sources ---
+	msg += "\n🌐 *Sources:*\n"
+	stale := time.Duration(3*Config.Interval) * time.Second
+	for i := range Config.OTPApiURLs {
+		idx := i + 1
+		st, polled := sources[idx]
+		switch {
+		case !polled:
+			msg += fmt.Sprintf("⚪ %s — not polled yet\n", sourceName(idx))
+		case st.LastErr != "":
+			msg += fmt.Sprintf("🔴 %s — %s (%d failures)", sourceName(idx), st.LastErr, st.Failures)
+			if !st.LastOK.IsZero() {
+				msg += ", last ok " + formatAge(st.LastOK)
+			}
+			msg += "\n"
+		case time.Since(st.LastPoll) > stale+10*time.Second:
+			msg += fmt.Sprintf("🟡 %s — last poll %s\n", sourceName(idx), formatAge(st.LastPoll))
+		default:
+			msg += fmt.Sprintf("🟢 %s — %d rows, polled %s\n", sourceName(idx), st.Rows, formatAge(st.LastPoll))
+		}
+	}
+	c.Reply(msg)
+}
+
+// formatUptime 2d 3h / 3h 12m / 5m
+func formatUptime(d time.Duration) string {
+	days, hours, mins := int(d.Hours())/24, int(d.Hours())%24, int(d.Minutes())%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, mins)
+	}
+	return fmt.Sprintf("%dm", mins)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordSourcePollHidesURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+	feed := srv.URL + "/feed?token=s3cret"
+
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	_, timeout := client.Get(feed)
+	_, refused := http.Get("http://127.0.0.1:1/feed?token=s3cret")
+
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{timeout, "Timeout"},
+		{refused, "Connection failed"},
+		{fmt.Errorf("Invalid JSON (HTTP 502)"), "Invalid JSON (HTTP 502)"},
+	} {
+		recordSourcePoll(99, 0, tc.err)
+		statusMutex.Lock()
+		got := sourceStates[99].LastErr
+		statusMutex.Unlock()
+		if got != tc.want || strings.Contains(got, "s3cret") {
+			t.Fatalf("LastErr = %q, want %q", got, tc.want)
+		}
+	}
+
+	statusMutex.Lock()
+	delete(sourceStates, 99)
+	statusMutex.Unlock()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// ---------------------------------------------------------
+// 👁️ NUMBER WATCH (Private DM Delivery)
+// ---------------------------------------------------------
+
+// deliverWatches اس نمبر کو watch کرنے والوں کو مکمل نمبر اور کوڈ DM کرتا ہے
+// یہ چینل ڈیلیوری کے علاوہ ہے، اس کی جگہ نہیں
+func deliverWatches(rec OTPRecord, cFlag, fullMsg string) {
+	watchers := GetWatchersForNumber(rec.Phone)
+	if len(watchers) == 0 {
+		return
+	}
+
+	body := formatWatchMessage(cFlag, rec.Service, rec.Source, rec.RawTime, rec.Country, rec.Phone, rec.Code, fullMsg)
+
+	for _, w := range watchers {
+		// پرانی watches (جب کوئی بھی .watch کر سکتا تھا) ڈیلیٹ نہیں ہوتیں، صرف رکی رہتی ہیں
+		if !isAdmin(w.Watcher) && loadedClient(w.Watcher) == nil {
+			continue
+		}
+		session, cli := pickWatchSession(w.Session)
+		if cli == nil {
+			fmt.Printf("      ⚠️ [WATCH] No healthy session to DM %s\n", w.Watcher)
+			continue
+		}
+
+		fmt.Printf("      👁️ [WATCH] DM to %s via %s ... ", w.DMJID, session)
+
+		jid, err := types.ParseJID(w.DMJID)
+		if err == nil {
+			_, err = cli.SendMessage(context.Background(), jid, &waProto.Message{
+				Conversation: proto.String(body),
+			})
+		}
+		LogDelivery(rec.MsgID, w.DMJID, w.Watcher, session, err)
+		PublishDeliveryEvent(rec, w.DMJID, w.Watcher, session, err)
+
+		if err != nil {
+			fmt.Printf("❌ FAILED: %v\n", err)
+		} else {
+			fmt.Printf("✅ SUCCESS!\n")
+		}
+	}
+}
+
+// loadedClient اس process میں لوڈ شدہ سیشن (disconnected بھی)، ورنہ nil
+func loadedClient(session string) *whatsmeow.Client {
+	ClientMutex.Lock()
+	defer ClientMutex.Unlock()
+	return ActiveClients[session]
+}
+
+// pickWatchSession پہلے وہ سیشن جس پر .watch کیا گیا تھا، ورنہ کوئی بھی healthy سیشن
+func pickWatchSession(preferred string) (string, *whatsmeow.Client) {
+	ClientMutex.Lock()
+	defer ClientMutex.Unlock()
+
+	if c, ok := ActiveClients[preferred]; ok && c.IsConnected() && c.IsLoggedIn() {
+		return preferred, c
+	}
+
+	sessions := make([]string, 0, len(ActiveClients))
+	for jidStr := range ActiveClients {
+		sessions = append(sessions, jidStr)
+	}
+	sort.Strings(sessions)
+	for _, jidStr := range sessions {
+		c := ActiveClients[jidStr]
+		if c.IsConnected() && c.IsLoggedIn() {
+			return jidStr, c
+		}
+	}
+	return "", nil
+}
+
+func formatWatchMessage(cFlag, service string, apiIdx int, rawTime, country, phone, otp, fullMsg string) string {
+	return fmt.Sprintf("👁️ *Watched Number OTP* ⚡\n\n"+
+		"> *Time:* %s\n"+
+		"> *Country:* %s %s\n"+
+		"   *Number:* *%s*\n"+
+		"> *Service:* %s\n"+
+		"   *OTP:* *%s*\n"+
+		"> *Source:* API %d\n\n"+
+		"*Full Message:*\n"+
+		"%s",
+		rawTime, cFlag, country, phone, strings.ToUpper(service), otp, apiIdx, fullMsg)
+}
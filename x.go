@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// 📦 EXPORT (OTP + Delivery History, CSV / JSON)
+// ---------------------------------------------------------
+
+const exportMaxRows = 50000
+
+type DeliveryRecord struct {
+	MsgID     string    `json:"msg_id"`
+	Target    string    `json:"target"`
+	Owner     string    `json:"owner"`
+	Session   string    `json:"session"`
+	Kind      string    `json:"kind"` // whatsapp | telegram | discord
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Phone     string    `json:"phone"`
+	Service   string    `json:"service"`
+	Country   string    `json:"country"`
+	Source    int       `json:"source"`
+}
+
+// SearchDeliveries delivery_log کو otp_history کے ساتھ join کر کے فلٹر کرتا ہے
+// owner خالی ہو تو سب (admin)، تاریخ فلٹر ڈیلیوری کے وقت پر
+func SearchDeliveries(f OTPFilter, owner string) []DeliveryRecord {
+	conds := []string{}
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if owner != "" {
+		conds = append(conds, "d.owner = "+arg(owner))
+	}
+	if f.Country != "" {
+		conds = append(conds, "LOWER(h.country) = "+arg(strings.ToLower(f.Country)))
+	}
+	if f.Service != "" {
+		conds = append(conds, "LOWER(h.service) LIKE "+arg("%"+strings.ToLower(f.Service)+"%"))
+	}
+	if f.Source > 0 {
+		conds = append(conds, "h.source = "+arg(f.Source))
+	}
+	if f.Phone != "" {
+		conds = append(conds, "(h.phone = "+arg(f.Phone)+" OR h.phone LIKE "+arg("%"+f.Phone)+")")
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "d.created_at >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "d.created_at < "+arg(f.To))
+	}
+
+	query := `SELECT d.msg_id, d.target, d.owner, d.session, COALESCE(d.kind, 'whatsapp'), d.status, COALESCE(d.error, ''), d.created_at,
+		COALESCE(h.phone, ''), COALESCE(h.service, ''), COALESCE(h.country, ''), COALESCE(h.source, 0)
+		FROM delivery_log d LEFT JOIN otp_history h ON h.msg_id = d.msg_id`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	limit := f.Limit
+	if limit <= 0 || limit > exportMaxRows {
+		limit = exportMaxRows
+	}
+	query += fmt.Sprintf(" ORDER BY d.created_at DESC LIMIT %d", limit)
+
+	list := []DeliveryRecord{}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		fmt.Printf("❌ [EXPORT] %v\n", err)
+		return list
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r DeliveryRecord
+		if rows.Scan(&r.MsgID, &r.Target, &r.Owner, &r.Session, &r.Kind, &r.Status, &r.Error, &r.CreatedAt,
+			&r.Phone, &r.Service, &r.Country, &r.Source) == nil {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+func writeDeliveryCSV(records []DeliveryRecord) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"created_at", "msg_id", "target", "owner", "session", "kind", "status", "error", "phone", "service", "country", "source"})
+	for _, r := range records {
+		w.Write([]string{
+			r.CreatedAt.UTC().Format(time.RFC3339),
+			r.MsgID,
+			r.Target,
+			r.Owner,
+			r.Session,
+			r.Kind,
+			r.Status,
+			r.Error,
+			r.Phone,
+			r.Service,
+			r.Country,
+			sourceName(r.Source),
+		})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// buildExport فائل کا نام، MIME اور ڈیٹا
+// kind: otp | deliveries، format: csv | json
+func buildExport(kind, format string, f OTPFilter, user string, admin bool) (string, string, []byte, int, error) {
+	if format != "csv" && format != "json" {
+		return "", "", nil, 0, fmt.Errorf("Unknown format: %s (csv|json)", format)
+	}
+	stamp := time.Now().UTC().Format("20060102-150405")
+
+	var data []byte
+	var count int
+	switch kind {
+	case "otp", "otps":
+		kind = "otp"
+		restrictForUser(&f, user, admin)
+		f.Limit, f.Offset = exportMaxRows, 0
+		records, _ := SearchOTPRecords(f)
+		count = len(records)
+		if format == "csv" {
+			data = writeOTPCSV(records)
+		} else {
+			data, _ = json.MarshalIndent(records, "", "  ")
+		}
+
+	case "deliveries", "delivery":
+		kind = "deliveries"
+		owner := user
+		if admin {
+			owner = ""
+		}
+		records := SearchDeliveries(f, owner)
+		count = len(records)
+		if format == "csv" {
+			data = writeDeliveryCSV(records)
+		} else {
+			data, _ = json.MarshalIndent(records, "", "  ")
+		}
+
+	default:
+		return "", "", nil, 0, fmt.Errorf("Unknown export type: %s (otp|deliveries)", kind)
+	}
+
+	mime := "text/csv"
+	if format == "json" {
+		mime = "application/json"
+	}
+	return fmt.Sprintf("%s_%s.%s", kind, stamp, format), mime, data, count, nil
+}
+
+// GET /api/export?type=otp|deliveries&format=csv|json&from=2025-01-01&to=2025-02-01&country=&service=&source=
+func handleExportAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	user, admin, ok := authenticateRequest(r)
+	if !ok {
+		http.Error(w, `{"error":"Unauthorized"}`, 401)
+		return
+	}
+
+	kind := strings.ToLower(r.URL.Query().Get("type"))
+	if kind == "" {
+		kind = "otp"
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+
+	name, mime, data, _, err := buildExport(kind, format, otpFilterFromQuery(r), user, admin)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 400)
+		return
+	}
+	w.Header().Set("Content-Type", mime)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Write(data)
+}